@@ -0,0 +1,32 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// BESTSpace is the marker of a space in the BEST corpus format
+const BESTSpace = "<space>"
+
+// FormatBEST joins tokens with | in the style of the BEST corpus, every
+// whitespace character of a whitespace token is written as BESTSpace
+func FormatBEST(tokens []string) string {
+	var out strings.Builder
+	for i, token := range tokens {
+		if i > 0 {
+			out.WriteByte('|')
+		}
+		if strings.TrimSpace(token) != "" {
+			out.WriteString(token)
+			continue
+		}
+		for _, ch := range token {
+			if unicode.IsSpace(ch) {
+				out.WriteString(BESTSpace)
+			} else {
+				out.WriteRune(ch)
+			}
+		}
+	}
+	return out.String()
+}
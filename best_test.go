@@ -0,0 +1,16 @@
+package main
+
+import "testing"
+
+func TestFormatBEST(t *testing.T) {
+	w := &SegmenterWorker{
+		dict: MakePrefixTree([]string{"ไป", "กิน", "ข้าว", "ที่", "บ้าน"}),
+		BEST: true,
+	}
+
+	got := w.formatLine(w.newSegmenter(), LineInput{textRunes: []rune("ไปกินข้าว  ที่บ้าน")})
+
+	if expect := "ไป|กิน|ข้าว|<space><space>|ที่|บ้าน\n"; got != expect {
+		t.Errorf("Expect %q got %q", expect, got)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"sort"
+	"sync"
+)
+
+// DictBuilder builds a PrefixTree one word at a time. Every added word takes
+// the next node ID, so IDs stay stable no matter what is added later.
+type DictBuilder struct {
+	tab    PrefixTree
+	nextID int
+}
+
+// NewDictBuilder creates an empty DictBuilder
+func NewDictBuilder() *DictBuilder {
+	return &DictBuilder{
+		tab: make(PrefixTree),
+	}
+}
+
+// AddWord adds a word to the prefix tree. It only looks up and adds the
+// nodes of word, so it takes the same time however large the tree is.
+func (b *DictBuilder) AddWord(word string) {
+	id := b.nextID
+	b.nextID++
+
+	rowNo := 0
+	runes := []rune(word)
+	len := len(runes)
+
+	for j, ch := range runes {
+		isFinal := ((j + 1) == len)
+		node := PrefixTreeNode{rowNo, j, ch}
+
+		if child, found := b.tab[node]; !found {
+			b.tab[node] = PrefixTreePointer{id, isFinal}
+			rowNo = id
+		} else {
+			// word may be a prefix of a word added before
+			if isFinal && !child.IsFinal {
+				child.IsFinal = true
+				b.tab[node] = child
+			}
+			rowNo = child.ChildID
+		}
+	}
+}
+
+// Build returns the prefix tree. Words added after Build are added to the
+// returned tree as well.
+func (b *DictBuilder) Build() PrefixTree {
+	return b.tab
+}
+
+// MakePrefixTreeParallel builds the same prefix tree as MakePrefixTree using
+// up to workers goroutines. Words are partitioned by their first rune so the
+// sub-tries share no node and can be merged as they are.
+func MakePrefixTreeParallel(words []string, workers int) PrefixTree {
+	lines := make([]string, len(words))
+	copy(lines, words)
+	sort.Strings(lines)
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	// split lines into about workers parts, never between two words with
+	// the same first rune
+	var bounds []int
+	size := len(lines)/workers + 1
+	for i := 0; i < len(lines); {
+		bounds = append(bounds, i)
+		i += size
+		for i < len(lines) && i > 0 && firstRune(lines[i]) == firstRune(lines[i-1]) {
+			i++
+		}
+	}
+	bounds = append(bounds, len(lines))
+
+	parts := make([]PrefixTree, len(bounds)-1)
+	var wg sync.WaitGroup
+	for p := range parts {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+
+			b := NewDictBuilder()
+			b.nextID = bounds[p]
+			for _, line := range lines[bounds[p]:bounds[p+1]] {
+				b.AddWord(line)
+			}
+			parts[p] = b.Build()
+		}(p)
+	}
+	wg.Wait()
+
+	n := 0
+	for _, part := range parts {
+		n += len(part)
+	}
+	tab := make(PrefixTree, n)
+	for _, part := range parts {
+		for node, child := range part {
+			tab[node] = child
+		}
+	}
+
+	return tab
+}
+
+func firstRune(s string) rune {
+	for _, ch := range s {
+		return ch
+	}
+	return -1
+}
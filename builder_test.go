@@ -0,0 +1,136 @@
+package main
+
+import (
+	"reflect"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+func TestDictBuilderMatchesMakePrefixTree(t *testing.T) {
+	words := []string{"กา", "กาก", "กิน", "ข้าว", "ไป"}
+
+	b := NewDictBuilder()
+	for _, word := range words {
+		b.AddWord(word)
+	}
+
+	if expect, got := MakePrefixTree(words), b.Build(); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+}
+
+func TestDictBuilderUnsortedWords(t *testing.T) {
+	words := []string{"ไป", "กาก", "ข้าว", "กา", "กิน"}
+
+	b := NewDictBuilder()
+	for _, word := range words {
+		b.AddWord(word)
+	}
+
+	batch := NewSegmenter(MakePrefixTree(words))
+	incremental := NewSegmenter(b.Build())
+
+	for _, text := range []string{"ไปกินข้าว", "กากกา", "กาไปกาก"} {
+		expect := batch.Segment([]rune(text))
+		if got := incremental.Segment([]rune(text)); !reflect.DeepEqual(expect, got) {
+			t.Errorf("Expect %v got %v", expect, got)
+		}
+	}
+}
+
+func TestDictBuilderAddAfterBuild(t *testing.T) {
+	b := NewDictBuilder()
+	for _, word := range []string{"ไป", "กิน", "ข้าว"} {
+		b.AddWord(word)
+	}
+	dict := b.Build()
+
+	before := make(PrefixTree, len(dict))
+	for node, child := range dict {
+		before[node] = child
+	}
+
+	b.AddWord("กินข้าว")
+	b.AddWord("ไปไหน")
+
+	for node, child := range before {
+		if got := dict[node]; got != child {
+			t.Errorf("Expect node %v to stay %v got %v", node, child, got)
+		}
+	}
+
+	expect := []string{"กิน", "กินข้าว", "ข้าว", "ไป", "ไปไหน"}
+	if got := dict.Words(); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+	if got := NewSegmenter(dict).Segment([]rune("ไปไหนกินข้าว")); !reflect.DeepEqual([]string{"ไปไหน", "กินข้าว"}, got) {
+		t.Errorf("Expect added words to segment got %v", got)
+	}
+}
+
+func BenchmarkDictBuilderAddWord(b *testing.B) {
+	dict, err := LoadDefaultDict()
+	if err != nil {
+		b.Fatal(err)
+	}
+	words := dict.Words()
+
+	for _, size := range []int{1000, 10000, len(words)} {
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			builder := NewDictBuilder()
+			for _, word := range words[:size] {
+				builder.AddWord(word)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				builder.AddWord(words[n%len(words)] + "ฮ")
+			}
+		})
+	}
+}
+
+func TestMakePrefixTreeParallel(t *testing.T) {
+	dict, err := LoadDefaultDict()
+	if err != nil {
+		t.Fatal(err)
+	}
+	words := dict.Words()
+
+	expect := MakePrefixTree(words)
+	for _, workers := range []int{1, 3, 8} {
+		if got := MakePrefixTreeParallel(words, workers); !reflect.DeepEqual(expect, got) {
+			t.Errorf("Expect parallel build with %d workers to equal sequential build", workers)
+		}
+	}
+}
+
+func BenchmarkMakePrefixTree(b *testing.B) {
+	dict, err := LoadDefaultDict()
+	if err != nil {
+		b.Fatal(err)
+	}
+	words := dict.Words()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		MakePrefixTree(words)
+	}
+}
+
+func BenchmarkMakePrefixTreeParallel(b *testing.B) {
+	dict, err := LoadDefaultDict()
+	if err != nil {
+		b.Fatal(err)
+	}
+	words := dict.Words()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		MakePrefixTreeParallel(words, runtime.NumCPU())
+	}
+}
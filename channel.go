@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// LineTokens are the tokens of a line of input
+type LineTokens struct {
+	LineNo int
+	Tokens []string
+}
+
+// TokenChannel segments every line read from r on NumCPU goroutines and
+// sends the tokens of each line as they are ready. Lines arrive in input
+// order when OrderedTokens is set. Both channels are closed at the end of
+// r, a read error is sent on the error channel before it is closed, so
+// drain the tokens before waiting for the error.
+func (w *SegmenterWorker) TokenChannel(r io.Reader) (<-chan LineTokens, <-chan error) {
+	lines := make(chan LineInput, runtime.NumCPU())
+	segmented := make(chan LineTokens, runtime.NumCPU())
+	out := segmented
+	errc := make(chan error, 1)
+
+	go func() {
+		defer close(lines)
+		defer close(errc)
+
+		scanner := bufio.NewScanner(r)
+		i := 0
+		for scanner.Scan() {
+			text := scanner.Bytes()
+			if i == 0 {
+				text = bytes.TrimPrefix(text, bom)
+			}
+			lines <- LineInput{
+				lineNo:    i,
+				textRunes: []rune(string(text)),
+			}
+			i++
+		}
+		if err := scanner.Err(); err != nil {
+			errc <- err
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for wc := 0; wc < runtime.NumCPU(); wc++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			sm := w.newSegmenter()
+			for lineInput := range lines {
+				segmented <- LineTokens{
+					LineNo: lineInput.lineNo,
+					Tokens: sm.Segment(lineInput.textRunes),
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(segmented)
+	}()
+
+	if w.OrderedTokens {
+		ordered := make(chan LineTokens, runtime.NumCPU())
+		go reorderLines(segmented, ordered)
+		out = ordered
+	}
+
+	return out, errc
+}
+
+// reorderLines sends the lines of in to out by line number
+func reorderLines(in <-chan LineTokens, out chan<- LineTokens) {
+	defer close(out)
+
+	pending := make(map[int]LineTokens)
+	next := 0
+	for line := range in {
+		pending[line.LineNo] = line
+		for {
+			ready, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			out <- ready
+			next++
+		}
+	}
+}
+
+// SegmentAllOrdered segments every line received from in on workers
+// goroutines and sends the tokens to out in input order, out is closed
+// after in is closed and drained. At most window lines are read ahead of
+// the last line sent, so memory stays bounded however long in is.
+func SegmentAllOrdered(dict PrefixTree, in <-chan string, out chan<- []string, workers, window int) {
+	if workers < 1 {
+		workers = 1
+	}
+	if window < 1 {
+		window = 1
+	}
+
+	lines := make(chan LineInput)
+	segmented := make(chan LineTokens, workers)
+	slots := make(chan struct{}, window)
+
+	go func() {
+		defer close(lines)
+
+		i := 0
+		for text := range in {
+			slots <- struct{}{}
+			lines <- LineInput{lineNo: i, textRunes: []rune(text)}
+			i++
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for wc := 0; wc < workers; wc++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			sm := NewSegmenter(dict)
+			for lineInput := range lines {
+				segmented <- LineTokens{lineInput.lineNo, sm.Segment(lineInput.textRunes)}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(segmented)
+	}()
+
+	pending := make(map[int][]string, window)
+	next := 0
+	for line := range segmented {
+		pending[line.LineNo] = line.Tokens
+		for {
+			tokens, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			out <- tokens
+			<-slots
+			next++
+		}
+	}
+	close(out)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTokenChannel(t *testing.T) {
+	w := NewSegmenterWorkerFromWords([]string{"ไป", "กิน", "ข้าว", "นอน"})
+	w.OrderedTokens = true
+
+	tokens, errc := w.TokenChannel(strings.NewReader("ไปกินข้าว\nนอน\n\nกินข้าว"))
+
+	var got []LineTokens
+	for line := range tokens {
+		got = append(got, line)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []LineTokens{
+		{0, []string{"ไป", "กิน", "ข้าว"}},
+		{1, []string{"นอน"}},
+		{2, []string{}},
+		{3, []string{"กิน", "ข้าว"}},
+	}
+	if !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) {
+	return 0, errors.New("broken")
+}
+
+func TestTokenChannelError(t *testing.T) {
+	w := NewSegmenterWorkerFromWords([]string{"ไป"})
+
+	tokens, errc := w.TokenChannel(errReader{})
+	for range tokens {
+	}
+	if err := <-errc; err == nil {
+		t.Error("Expect read error")
+	}
+}
+
+func TestSegmentAllOrdered(t *testing.T) {
+	dict := MakePrefixTree([]string{"ไป", "กิน", "ข้าว", "นอน"})
+	texts := []string{"ไปกินข้าว", "นอน", "กินข้าว", "ไปนอน", ""}
+
+	in := make(chan string)
+	out := make(chan []string)
+	go SegmentAllOrdered(dict, in, out, 4, 2)
+	go func() {
+		for i := 0; i < 200; i++ {
+			in <- texts[i%len(texts)]
+		}
+		close(in)
+	}()
+
+	sm := NewSegmenter(dict)
+	n := 0
+	for tokens := range out {
+		if expect := sm.Segment([]rune(texts[n%len(texts)])); !reflect.DeepEqual(expect, tokens) {
+			t.Fatalf("Expect line %d %v got %v", n, expect, tokens)
+		}
+		n++
+	}
+	if n != 200 {
+		t.Errorf("Expect 200 lines got %d", n)
+	}
+}
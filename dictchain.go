@@ -0,0 +1,114 @@
+package main
+
+// DictChain is an ordered list of dictionaries used together without
+// merging them. Text is segmented with the first dictionary, the words it
+// leaves unknown are segmented with the rest of the chain.
+type DictChain []PrefixTree
+
+// IsWordStart reports whether a word of any dictionary begins with ch
+func (c DictChain) IsWordStart(ch rune) bool {
+	for _, dict := range c {
+		if dict.IsWordStart(ch) {
+			return true
+		}
+	}
+	return false
+}
+
+// LongestMatchAt returns the rune length of the longest word of any
+// dictionary starting at runes[i], or 0 when no word starts there
+func (c DictChain) LongestMatchAt(runes []rune, i int) int {
+	longest := 0
+	for _, dict := range c {
+		if n := dict.LongestMatchAt(runes, i); n > longest {
+			longest = n
+		}
+	}
+	return longest
+}
+
+// SetDictChain replaces the dictionary of the Segmenter with chain, the
+// first dictionary is primary and the others are fallbacks for its unknown
+// words. Node IDs reported by SegmentNodeIDs are of the dictionary the word
+// was found in. Latin folding and SegmentNBest only use the first
+// dictionary.
+func (sm *Segmenter) SetDictChain(chain DictChain) {
+	sm.dict = nil
+	if len(chain) > 0 {
+		sm.dict = chain[0]
+	}
+	sm.chain = chain
+	sm.starts = sm.dict.firstRunes()
+
+	sm.fallback = nil
+	if len(chain) > 1 {
+		sm.fallback = NewSegmenterChain(chain[1:])
+	}
+}
+
+// NewSegmenterChain creates a Segmenter for the dictionaries of chain
+func NewSegmenterChain(chain DictChain) *Segmenter {
+	sm := &Segmenter{GroupUnknown: true}
+	sm.SetDictChain(chain)
+	return sm
+}
+
+// segmentFallback segments the unknown words on the path of line with the
+// fallback dictionaries and replaces their edges by the fallback edges when
+// the fallback knows a word of them
+func (sm *Segmenter) segmentFallback(line []rune) {
+	var ends []int
+	for e := len(line); e > 0; e = sm.path[e].S {
+		ends = append(ends, e)
+	}
+
+	fb := sm.fallback
+	fb.HardBoundaries = sm.HardBoundaries
+	fb.Classifiers = sm.Classifiers
+	fb.Joiners = sm.Joiners
+	fb.UnicodeSpaces = sm.UnicodeSpaces
+	fb.GroupUnknown = sm.GroupUnknown
+	fb.UnknownChunk = sm.UnknownChunk
+	fb.UnkPenalty = sm.UnkPenalty
+	fb.LengthBonus = sm.LengthBonus
+	fb.Comparator = sm.Comparator
+
+	// the counts after a replaced edge change by wordDelta and unkDelta
+	wordDelta, unkDelta := 0, 0
+	for k := len(ends) - 1; k >= 0; k-- {
+		e := ends[k]
+		s := sm.path[e].S
+		old := sm.path[e]
+		if !sm.oov[e] || !fb.splitUnknown(line[s:e]) {
+			sm.path[e].WordCount += wordDelta
+			sm.path[e].UnkCount += unkDelta
+			continue
+		}
+
+		source := sm.path[s]
+		for j := e - s; j > 0; j = fb.path[j].S {
+			sm.path[s+j] = Edge{
+				S:         s + fb.path[j].S,
+				WordCount: source.WordCount + fb.path[j].WordCount,
+				UnkCount:  source.UnkCount + fb.path[j].UnkCount,
+			}
+			sm.ties[s+j] = fb.ties[j]
+			sm.nodes[s+j] = fb.nodes[j]
+			sm.oov[s+j] = fb.oov[j]
+		}
+		wordDelta = sm.path[e].WordCount - old.WordCount
+		unkDelta = sm.path[e].UnkCount - old.UnkCount
+	}
+}
+
+// splitUnknown builds the path of the unknown word and reports whether it
+// has a known word
+func (sm *Segmenter) splitUnknown(word []rune) bool {
+	sm.BuildPath(word)
+	for e := len(word); e > 0; e = sm.path[e].S {
+		if !sm.oov[e] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDictChain(t *testing.T) {
+	primary := MakePrefixTree([]string{"ไป", "กิน"})
+	fallback := MakePrefixTree([]string{"ข้าว", "กินข้าว", "ไปกิน"})
+
+	tests := []struct {
+		text           string
+		primary, chain []string
+	}{
+		// only the unknown word of the primary segmentation is segmented
+		// with the fallback, its กินข้าว and ไปกิน do not win over กิน
+		{"ไปกินข้าวฮฮ", []string{"ไป", "กิน", "ข้าวฮฮ"}, []string{"ไป", "กิน", "ข้าว", "ฮฮ"}},
+		{"ไปกิน", []string{"ไป", "กิน"}, []string{"ไป", "กิน"}},
+		{"ฮฮ ไป", []string{"ฮฮ", " ", "ไป"}, []string{"ฮฮ", " ", "ไป"}},
+	}
+	sm := NewSegmenterChain(DictChain{primary, fallback})
+	for _, test := range tests {
+		text := []rune(test.text)
+		if got := NewSegmenter(primary).Segment(text); !reflect.DeepEqual(test.primary, got) {
+			t.Errorf("Expect %v got %v", test.primary, got)
+		}
+		if got := sm.Segment(text); !reflect.DeepEqual(test.chain, got) {
+			t.Errorf("Expect %v got %v", test.chain, got)
+		}
+	}
+
+	tokens, unknown := sm.SegmentUnknown([]rune("ไปกินข้าวฮฮ"))
+	if expect := []bool{false, false, false, true}; !reflect.DeepEqual(expect, unknown) {
+		t.Errorf("Expect %v unknown of %v got %v", expect, tokens, unknown)
+	}
+	_, deltas := sm.SegmentUnkDeltas([]rune("ไปกินข้าวฮฮ ฮ"))
+	if expect := []int{0, 0, 0, 1, 0, 1}; !reflect.DeepEqual(expect, deltas) {
+		t.Errorf("Expect deltas %v got %v", expect, deltas)
+	}
+
+	chain := DictChain{primary, fallback}
+	if n := chain.LongestMatchAt([]rune("ไปกินข้าวฮฮ"), 2); n != 7 {
+		t.Errorf("Expect longest match 7 got %d", n)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// WriteDOT writes the prefix tree to w as a Graphviz DOT graph. Edges are
+// labeled with their character and nodes ending a word are double circles.
+func (t PrefixTree) WriteDOT(w io.Writer) error {
+	return t.WriteDOTPrefix(w, "")
+}
+
+// WriteDOTPrefix writes the subtree of the words beginning with prefix like
+// WriteDOT, the path from the root to the subtree is included
+func (t PrefixTree) WriteDOTPrefix(w io.Writer, prefix string) error {
+	children := make(map[trieState][]trieEdge)
+	for node, child := range t {
+		state := trieState{node.NodeID, node.Offset}
+		children[state] = append(children[state], trieEdge{node.Ch, child})
+	}
+	for _, es := range children {
+		sort.Slice(es, func(i, j int) bool { return es[i].Ch < es[j].Ch })
+	}
+
+	out := bufio.NewWriter(w)
+	out.WriteString("digraph PrefixTree {\n")
+	out.WriteString("\tnode [shape=circle];\n")
+
+	writeEdge := func(from trieState, e trieEdge) trieState {
+		to := trieState{e.ChildID, from.Offset + 1}
+		if e.IsFinal {
+			fmt.Fprintf(out, "\t%s [shape=doublecircle];\n", dotNode(to))
+		}
+		fmt.Fprintf(out, "\t%s -> %s [label=%q];\n", dotNode(from), dotNode(to), string(e.Ch))
+		return to
+	}
+
+	state := trieState{0, 0}
+	for _, ch := range prefix {
+		found := false
+		for _, e := range children[state] {
+			if e.Ch == ch {
+				state = writeEdge(state, e)
+				found = true
+				break
+			}
+		}
+		if !found {
+			out.WriteString("}\n")
+			return out.Flush()
+		}
+	}
+
+	// nodes of a minimized tree may be reached more than once
+	visited := make(map[trieState]bool)
+	var walk func(state trieState)
+	walk = func(state trieState) {
+		if visited[state] {
+			return
+		}
+		visited[state] = true
+		for _, e := range children[state] {
+			walk(writeEdge(state, e))
+		}
+	}
+	walk(state)
+
+	out.WriteString("}\n")
+	return out.Flush()
+}
+
+// dotNode is the DOT node name of state
+func dotNode(state trieState) string {
+	return fmt.Sprintf("n%d_%d", state.NodeID, state.Offset)
+}
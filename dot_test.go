@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteDOT(t *testing.T) {
+	dict := MakePrefixTree([]string{"มา", "มาก", "ไป"})
+
+	var out bytes.Buffer
+	if err := dict.WriteDOT(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	dot := out.String()
+	for _, expect := range []string{
+		"digraph PrefixTree {",
+		`n0_0 -> n0_1 [label="ม"];`,
+		`n0_1 -> n0_2 [label="า"];`,
+		"n0_2 [shape=doublecircle];",
+		`n0_2 -> n1_3 [label="ก"];`,
+		`n0_0 -> n2_1 [label="ไ"];`,
+	} {
+		if !strings.Contains(dot, expect) {
+			t.Errorf("Expect DOT to contain %q got\n%s", expect, dot)
+		}
+	}
+
+	out.Reset()
+	if err := dict.WriteDOTPrefix(&out, "มา"); err != nil {
+		t.Fatal(err)
+	}
+	if dot := out.String(); strings.Contains(dot, `"ไ"`) || !strings.Contains(dot, `"ก"`) {
+		t.Errorf("Expect only the มา subtree got\n%s", dot)
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import "errors"
+
+// ErrEmptyDict is returned when a dictionary has no words
+var ErrEmptyDict = errors.New("dictionary has no words")
+
+// DictError is an error loading the dictionary file Path, errors.Is and
+// errors.As see the underlying Err, like fs.ErrNotExist or ErrEmptyDict
+type DictError struct {
+	Path string
+	Err  error
+}
+
+func (e *DictError) Error() string {
+	return "could not load dictionary " + e.Path + ": " + e.Err.Error()
+}
+
+func (e *DictError) Unwrap() error {
+	return e.Err
+}
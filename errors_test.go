@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadDictErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	missing := filepath.Join(dir, "missing.txt")
+	_, err := LoadDict(missing)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expect fs.ErrNotExist got %v", err)
+	}
+	var dictErr *DictError
+	if !errors.As(err, &dictErr) || dictErr.Path != missing {
+		t.Errorf("Expect DictError for %s got %v", missing, err)
+	}
+
+	empty := filepath.Join(dir, "empty.txt")
+	if err := os.WriteFile(empty, []byte("\n\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadDict(empty); !errors.Is(err, ErrEmptyDict) {
+		t.Errorf("Expect ErrEmptyDict got %v", err)
+	}
+	if _, err := LoadDictReader(strings.NewReader("")); !errors.Is(err, ErrEmptyDict) {
+		t.Errorf("Expect ErrEmptyDict got %v", err)
+	}
+
+	if _, err := LoadDictFieldsReader(strings.NewReader(" \t\n")); !errors.Is(err, ErrEmptyDict) {
+		t.Errorf("Expect ErrEmptyDict got %v", err)
+	}
+	fsys := fstest.MapFS{"empty.txt": &fstest.MapFile{}}
+	if _, err := LoadDictFS(fsys, "empty.txt"); !errors.Is(err, ErrEmptyDict) {
+		t.Errorf("Expect ErrEmptyDict got %v", err)
+	}
+
+	if os.Geteuid() == 0 {
+		t.Skip("permissions are not checked for root")
+	}
+	locked := filepath.Join(dir, "locked.txt")
+	if err := os.WriteFile(locked, []byte("ไป\n"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadDict(locked); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Expect fs.ErrPermission got %v", err)
+	}
+}
+
+func TestMakeDictStrict(t *testing.T) {
+	if _, err := MakeDictStrict([]string{"", ""}); !errors.Is(err, ErrEmptyDict) {
+		t.Errorf("Expect ErrEmptyDict got %v", err)
+	}
+
+	dict, err := MakeDictStrict([]string{"ไป", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := dict.Len(); n != 1 {
+		t.Errorf("Expect 1 word got %d", n)
+	}
+}
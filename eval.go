@@ -0,0 +1,78 @@
+package main
+
+// tokenSpan is the rune range of a token in its sentence
+type tokenSpan struct {
+	Start int
+	End   int
+}
+
+func tokenSpans(tokens []string) map[tokenSpan]bool {
+	spans := make(map[tokenSpan]bool, len(tokens))
+	start := 0
+	for _, token := range tokens {
+		end := start + len([]rune(token))
+		spans[tokenSpan{start, end}] = true
+		start = end
+	}
+	return spans
+}
+
+// ScoreTokens compares a segmentation against the gold segmentation of the
+// same text. A token is correct when both segmentations have a token with
+// the same rune range.
+func ScoreTokens(gold, predicted []string) (correct, goldCount, predictedCount int) {
+	goldSpans := tokenSpans(gold)
+	for span := range tokenSpans(predicted) {
+		if goldSpans[span] {
+			correct++
+		}
+	}
+	return correct, len(gold), len(predicted)
+}
+
+// PrecisionRecall returns token level precision and recall from the summed
+// counts of ScoreTokens
+func PrecisionRecall(correct, goldCount, predictedCount int) (precision, recall float64) {
+	if predictedCount > 0 {
+		precision = float64(correct) / float64(predictedCount)
+	}
+	if goldCount > 0 {
+		recall = float64(correct) / float64(goldCount)
+	}
+	return precision, recall
+}
+
+// BoundaryDiff compares two segmentations of the same text by the rune
+// offsets of the boundaries between their tokens, added are the offsets
+// only b has and removed the offsets only a has, both in order
+func BoundaryDiff(a, b []string) (added, removed []int) {
+	ba, bb := tokenBoundaries(a), tokenBoundaries(b)
+	i, j := 0, 0
+	for i < len(ba) || j < len(bb) {
+		switch {
+		case j == len(bb) || (i < len(ba) && ba[i] < bb[j]):
+			removed = append(removed, ba[i])
+			i++
+		case i == len(ba) || bb[j] < ba[i]:
+			added = append(added, bb[j])
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+	return added, removed
+}
+
+// tokenBoundaries returns the rune offsets between tokens
+func tokenBoundaries(tokens []string) []int {
+	var bounds []int
+	end := 0
+	for i, token := range tokens {
+		end += len([]rune(token))
+		if i < len(tokens)-1 {
+			bounds = append(bounds, end)
+		}
+	}
+	return bounds
+}
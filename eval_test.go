@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScoreTokens(t *testing.T) {
+	correct, goldCount, predictedCount := ScoreTokens(
+		[]string{"ตา", "กลม", "มาก"},
+		[]string{"ตาก", "ลม", "มาก"},
+	)
+	if correct != 1 || goldCount != 3 || predictedCount != 3 {
+		t.Errorf("Expect 1 of 3 correct got %d of %d/%d", correct, goldCount, predictedCount)
+	}
+}
+
+func TestBoundaryDiff(t *testing.T) {
+	a := []string{"ตา", "กลม", "มาก", " ", "ไป"}
+	b := []string{"ตาก", "ลม", "มาก", " ", "ไป"}
+
+	added, removed := BoundaryDiff(a, b)
+	if expect := []int{3}; !reflect.DeepEqual(expect, added) {
+		t.Errorf("Expect added %v got %v", expect, added)
+	}
+	if expect := []int{2}; !reflect.DeepEqual(expect, removed) {
+		t.Errorf("Expect removed %v got %v", expect, removed)
+	}
+
+	added, removed = BoundaryDiff([]string{"กินข้าว"}, []string{"กิน", "ข้าว"})
+	if expect := []int{3}; !reflect.DeepEqual(expect, added) || removed != nil {
+		t.Errorf("Expect added %v and nothing removed got %v and %v", expect, added, removed)
+	}
+}
+
+func loadGold(tb testing.TB) [][]string {
+	f, err := os.Open("testdata/gold.txt")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer f.Close()
+
+	var gold [][]string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			gold = append(gold, strings.Split(line, "|"))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		tb.Fatal(err)
+	}
+	return gold
+}
+
+func scoreGold(sm *Segmenter, gold [][]string) (precision, recall float64) {
+	var correct, goldCount, predictedCount int
+	for _, tokens := range gold {
+		c, g, p := ScoreTokens(tokens, sm.Segment([]rune(strings.Join(tokens, ""))))
+		correct += c
+		goldCount += g
+		predictedCount += p
+	}
+	return PrecisionRecall(correct, goldCount, predictedCount)
+}
+
+func TestGoldStandard(t *testing.T) {
+	dict, err := LoadDefaultDict()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	precision, recall := scoreGold(NewSegmenter(dict), loadGold(t))
+	t.Logf("precision %.3f recall %.3f", precision, recall)
+
+	if precision < 0.7 {
+		t.Errorf("Expect precision of at least 0.7 got %.3f", precision)
+	}
+	if recall < 0.8 {
+		t.Errorf("Expect recall of at least 0.8 got %.3f", recall)
+	}
+}
+
+func BenchmarkGoldStandard(b *testing.B) {
+	dict, err := LoadDefaultDict()
+	if err != nil {
+		b.Fatal(err)
+	}
+	sm := NewSegmenter(dict)
+	gold := loadGold(b)
+
+	var precision, recall float64
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		precision, recall = scoreGold(sm, gold)
+	}
+
+	b.ReportMetric(precision, "precision")
+	b.ReportMetric(recall, "recall")
+}
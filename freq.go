@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"sort"
+)
+
+// TokenCount is a token with its number of occurrences
+type TokenCount struct {
+	Token string
+	Count int
+}
+
+// Frequencies segments every line read from in and counts each token
+func (w *SegmenterWorker) Frequencies(in io.Reader) (map[string]int, error) {
+	sm := w.newSegmenter()
+	freq := make(map[string]int)
+
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		for _, token := range sm.Segment([]rune(scanner.Text())) {
+			freq[token]++
+		}
+	}
+
+	return freq, scanner.Err()
+}
+
+// UniqueTokens segments every line read from in and returns every token
+// once, in the order of its first occurrence
+func (w *SegmenterWorker) UniqueTokens(in io.Reader) ([]string, error) {
+	sm := w.newSegmenter()
+	seen := make(map[string]struct{})
+	var tokens []string
+
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		for _, token := range sm.Segment([]rune(scanner.Text())) {
+			if _, found := seen[token]; !found {
+				seen[token] = struct{}{}
+				tokens = append(tokens, token)
+			}
+		}
+	}
+
+	return tokens, scanner.Err()
+}
+
+// DistinctTokens segments every line read from in and returns the number of
+// distinct tokens, space tokens of the worker Segmenters are not counted
+// when skipSpace is set
+func (w *SegmenterWorker) DistinctTokens(in io.Reader, skipSpace bool) (int, error) {
+	freq, err := w.Frequencies(in)
+	if err != nil {
+		return 0, err
+	}
+
+	n := len(freq)
+	if skipSpace {
+		sm := w.newSegmenter()
+		for token := range freq {
+			if sm.isSpaceToken(token) {
+				n--
+			}
+		}
+	}
+	return n, nil
+}
+
+// TopTokens returns the n most frequent tokens sorted by count descending,
+// tokens with the same count are sorted by token
+func TopTokens(freq map[string]int, n int) []TokenCount {
+	counts := make([]TokenCount, 0, len(freq))
+	for token, count := range freq {
+		counts = append(counts, TokenCount{token, count})
+	}
+
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Token < counts[j].Token
+	})
+
+	if n >= 0 && n < len(counts) {
+		counts = counts[:n]
+	}
+	return counts
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTopTokens(t *testing.T) {
+	w := &SegmenterWorker{
+		dict: MakePrefixTree([]string{"ไป", "กิน", "ข้าว", "น้ำ"}),
+	}
+
+	doc := "กินข้าวกินน้ำ\nไปกินข้าว\nกินน้ำ\n"
+	freq, err := w.Frequencies(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []TokenCount{{"กิน", 4}, {"ข้าว", 2}, {"น้ำ", 2}}
+	if got := TopTokens(freq, 3); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+}
+
+func TestDistinctTokens(t *testing.T) {
+	w := &SegmenterWorker{
+		dict: MakePrefixTree([]string{"ไป", "กิน", "ข้าว", "น้ำ"}),
+	}
+
+	doc := "กินข้าว กินน้ำ\nไปกินข้าว\nกินน้ำ ไป\n"
+	tests := []struct {
+		skipSpace bool
+		expect    int
+	}{
+		{false, 5},
+		{true, 4},
+	}
+	for _, test := range tests {
+		got, err := w.DistinctTokens(strings.NewReader(doc), test.skipSpace)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.expect {
+			t.Errorf("Expect %d distinct tokens skipping space %v got %d", test.expect, test.skipSpace, got)
+		}
+	}
+
+	// a zero-width space token is skipped like the worker segments it
+	w.UnicodeSpaces = true
+	got, err := w.DistinctTokens(strings.NewReader("กิน\u200bข้าว กิน\n"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 2 {
+		t.Errorf("Expect 2 distinct tokens got %d", got)
+	}
+}
+
+func TestUniqueTokens(t *testing.T) {
+	w := &SegmenterWorker{
+		dict: MakePrefixTree([]string{"ไป", "กิน", "ข้าว", "น้ำ"}),
+	}
+
+	doc := "กินข้าวกินน้ำ\nไปกินข้าว\nน้ำ ไป\n"
+	got, err := w.UniqueTokens(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []string{"กิน", "ข้าว", "น้ำ", "ไป", " "}
+	if !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %q got %q", expect, got)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"html"
+	"strings"
+)
+
+// FormatHTML wraps every token in a span of class tok, space tokens are
+// written as they are. Text is HTML escaped.
+func FormatHTML(tokens []string) string {
+	var out strings.Builder
+	for _, token := range tokens {
+		if IsSpaceToken(token) {
+			out.WriteString(html.EscapeString(token))
+			continue
+		}
+		out.WriteString(`<span class="tok">`)
+		out.WriteString(html.EscapeString(token))
+		out.WriteString(`</span>`)
+	}
+	return out.String()
+}
+
+// SegmentMarkup segments the text between <...> tags of textRunes, every
+// tag is passed through verbatim as its own token. A < without a closing >
+// is text.
+func (sm *Segmenter) SegmentMarkup(textRunes []rune) []string {
+	var tokens []string
+	start := 0
+	for i := 0; i < len(textRunes); i++ {
+		if textRunes[i] != '<' {
+			continue
+		}
+		end := i + 1
+		for end < len(textRunes) && textRunes[end] != '>' {
+			end++
+		}
+		if end == len(textRunes) {
+			break
+		}
+		if start < i {
+			tokens = append(tokens, sm.Segment(textRunes[start:i:i])...)
+		}
+		tokens = append(tokens, string(textRunes[i:end+1]))
+		start = end + 1
+		i = end
+	}
+	if start < len(textRunes) {
+		tokens = append(tokens, sm.Segment(textRunes[start:])...)
+	}
+
+	return tokens
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"html"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestFormatHTML(t *testing.T) {
+	w := &SegmenterWorker{
+		dict: MakePrefixTree([]string{"ไป", "กิน", "ข้าว"}),
+		HTML: true,
+	}
+	text := "ไป<กิน> ข้าว"
+
+	got := w.formatLine(w.newSegmenter(), LineInput{textRunes: []rune(text)})
+
+	expect := `<span class="tok">ไป</span><span class="tok">&lt;</span><span class="tok">กิน</span>` +
+		`<span class="tok">&gt;</span> <span class="tok">ข้าว</span>` + "\n"
+	if got != expect {
+		t.Errorf("Expect %q got %q", expect, got)
+	}
+
+	stripped := html.UnescapeString(regexp.MustCompile(`<[^>]*>`).ReplaceAllString(got, ""))
+	if stripped != text+"\n" {
+		t.Errorf("Expect stripped HTML to be %q got %q", text, stripped)
+	}
+}
+
+func TestSegmentMarkup(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป", "กิน", "ข้าว"}))
+
+	tests := []struct {
+		text   string
+		expect []string
+	}{
+		{"<b>ไปกิน</b>ข้าว", []string{"<b>", "ไป", "กิน", "</b>", "ข้าว"}},
+		{`<a href="x">ข้าว</a>`, []string{`<a href="x">`, "ข้าว", "</a>"}},
+		{"ไป<กิน", []string{"ไป", "<", "กิน"}},
+	}
+	for _, test := range tests {
+		if got := sm.SegmentMarkup([]rune(test.text)); !reflect.DeepEqual(test.expect, got) {
+			t.Errorf("Expect %q got %q", test.expect, got)
+		}
+	}
+
+	w := &SegmenterWorker{
+		dict:   MakePrefixTree([]string{"ไป", "กิน", "ข้าว"}),
+		Markup: true,
+	}
+	expect := "<b>|ไป|กิน|</b>|ข้าว\n"
+	if got := w.formatLine(w.newSegmenter(), LineInput{textRunes: []rune("<b>ไปกิน</b>ข้าว")}); got != expect {
+		t.Errorf("Expect %q got %q", expect, got)
+	}
+}
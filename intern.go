@@ -0,0 +1,48 @@
+package main
+
+// TokenInterner gives every distinct token a stable ID, IDs count up from
+// 0 in the order tokens are first seen. It is not safe for concurrent use.
+type TokenInterner struct {
+	ids    map[string]int
+	tokens []string
+}
+
+// NewTokenInterner creates an empty TokenInterner
+func NewTokenInterner() *TokenInterner {
+	return &TokenInterner{
+		ids: make(map[string]int),
+	}
+}
+
+// ID returns the ID of token, a new ID is taken when token is new
+func (in *TokenInterner) ID(token string) int {
+	if id, found := in.ids[token]; found {
+		return id
+	}
+
+	id := len(in.tokens)
+	in.ids[token] = id
+	in.tokens = append(in.tokens, token)
+	return id
+}
+
+// Token returns the token of id
+func (in *TokenInterner) Token(id int) string {
+	return in.tokens[id]
+}
+
+// Len returns the number of distinct tokens
+func (in *TokenInterner) Len() int {
+	return len(in.tokens)
+}
+
+// SegmentIDs segments textRunes like Segment and returns the IDs of the
+// tokens in interner
+func (sm *Segmenter) SegmentIDs(textRunes []rune, interner *TokenInterner) []int {
+	tokens := sm.Segment(textRunes)
+	ids := make([]int, len(tokens))
+	for i, token := range tokens {
+		ids[i] = interner.ID(token)
+	}
+	return ids
+}
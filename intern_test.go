@@ -0,0 +1,30 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSegmentIDs(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป", "กิน", "ข้าว"}))
+	interner := NewTokenInterner()
+
+	first := sm.SegmentIDs([]rune("ไปกินข้าว"), interner)
+	second := sm.SegmentIDs([]rune("กินข้าวไป"), interner)
+
+	if expect := []int{0, 1, 2}; !reflect.DeepEqual(expect, first) {
+		t.Errorf("Expect %v got %v", expect, first)
+	}
+	if expect := []int{1, 2, 0}; !reflect.DeepEqual(expect, second) {
+		t.Errorf("Expect %v got %v", expect, second)
+	}
+	if n := interner.Len(); n != 3 {
+		t.Errorf("Expect 3 tokens got %d", n)
+	}
+
+	for i, expect := range []string{"ไป", "กิน", "ข้าว"} {
+		if got := interner.Token(i); got != expect {
+			t.Errorf("Expect token %d to be %q got %q", i, expect, got)
+		}
+	}
+}
@@ -3,15 +3,25 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"compress/gzip"
+	"context"
 	"flag"
+	"fmt"
+	"io"
+	"io/fs"
 	"io/ioutil"
 	"log"
+	"math"
 	"os"
 	"path"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
 )
 
 // Edge - edge of word graph
@@ -48,7 +58,17 @@ type LineInput struct {
 	textRunes []rune
 }
 
-func IsSpace(ch rune) bool {
+// IsSpace reports whether ch belongs to a Space run, it may be replaced
+// before segmenting to change the space rules
+var IsSpace = DefaultIsSpace
+
+// IsLatin reports whether ch belongs to a Latin run, it may be replaced
+// before segmenting to change the Latin rules
+var IsLatin = DefaultIsLatin
+
+// DefaultIsSpace reports whether ch is a space, a double quote or a
+// parenthesis
+func DefaultIsSpace(ch rune) bool {
 	return ch == ' ' ||
 		ch == '\n' ||
 		ch == '\t' ||
@@ -59,25 +79,233 @@ func IsSpace(ch rune) bool {
 		ch == '”'
 }
 
-func IsLatin(ch rune) bool {
+// IsSymbol reports whether ch is an emoji or other symbol, consecutive
+// symbols are grouped into one token
+func IsSymbol(ch rune) bool {
+	return unicode.IsSymbol(ch) ||
+		(ch >= 0x1F000 && ch <= 0x1FAFF) ||
+		ch == '\u200d' ||
+		ch == '\ufe0f'
+}
+
+// IsDigit reports whether ch is an Arabic or Thai digit
+func IsDigit(ch rune) bool {
+	return (ch >= '0' && ch <= '9') ||
+		(ch >= '๐' && ch <= '๙')
+}
+
+// IsUnicodeSpace reports whether ch is a zero-width, narrow or no-break
+// space used as a word boundary in Thai text
+func IsUnicodeSpace(ch rune) bool {
+	return ch == '\u200b' ||
+		ch == '\u202f' ||
+		ch == '\u00a0'
+}
+
+// DefaultIsLatin reports whether ch is an ASCII letter
+func DefaultIsLatin(ch rune) bool {
 	return (ch >= 'A' && ch <= 'Z') ||
 		(ch >= 'a' && ch <= 'z')
 }
 
 // LoadDict is for loading a word list from file
 func LoadDict(path string) (PrefixTree, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, &DictError{path, err}
+	}
+	defer f.Close()
+
+	dict, err := LoadDictReader(f)
+	if err != nil {
+		return nil, &DictError{path, err}
+	}
+	return dict, nil
+}
+
+// LoadDictFS is for loading a word list from the file name of fsys
+func LoadDictFS(fsys fs.FS, name string) (PrefixTree, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, &DictError{name, err}
+	}
+	defer f.Close()
+
+	dict, err := LoadDictReader(f)
+	if err != nil {
+		return nil, &DictError{name, err}
+	}
+	return dict, nil
+}
+
+// LoadDictReader is for loading a word list, one word per line, from r.
+// Gzip compressed word lists are decompressed. ErrEmptyDict is returned
+// when r has no words.
+func LoadDictReader(r io.Reader) (PrefixTree, error) {
+	r, err := decompress(r)
+	if err != nil {
+		return nil, err
+	}
+
+	lines, err := readLines(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, ErrEmptyDict
+	}
+
+	return MakePrefixTree(lines), nil
+}
+
+// contextCheckLines is how many lines LoadDictContext reads or adds between
+// checks of its context
+const contextCheckLines = 1024
+
+// LoadDictContext is for loading a word list like LoadDictReader, it stops
+// with the error of ctx when ctx is done before the dictionary is built
+func LoadDictContext(ctx context.Context, r io.Reader) (PrefixTree, error) {
+	r, err := decompress(r)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]string, 0)
+	scanner := bufio.NewScanner(r)
+	for n := 0; scanner.Scan(); n++ {
+		if n%contextCheckLines == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		line := scanner.Text()
+		if n == 0 {
+			line = strings.TrimPrefix(line, string(bom))
+		}
+		if len(line) != 0 {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, ErrEmptyDict
+	}
+
+	sort.Strings(lines)
+	b := NewDictBuilder()
+	for n, line := range lines {
+		if n%contextCheckLines == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		b.AddWord(line)
+	}
+
+	return b.Build(), nil
+}
+
+// LoadDictFieldsReader is for loading a whitespace separated word list from
+// r. Gzip compressed word lists are decompressed.
+func LoadDictFieldsReader(r io.Reader) (PrefixTree, error) {
+	r, err := decompress(r)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	words := strings.Fields(string(bytes.TrimPrefix(b, bom)))
+	if len(words) == 0 {
+		return nil, ErrEmptyDict
+	}
+
+	return MakePrefixTree(words), nil
+}
+
+// LoadDictFreqReader is for loading a word list of word<TAB>frequency lines
+// from r, it returns the dictionary and the frequency of every word. A line
+// without a frequency counts 1.
+func LoadDictFreqReader(r io.Reader) (PrefixTree, map[string]int, error) {
+	r, err := decompress(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lines, err := readLines(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(lines) == 0 {
+		return nil, nil, ErrEmptyDict
+	}
+
+	words := make([]string, 0, len(lines))
+	freq := make(map[string]int, len(lines))
+	for i, line := range lines {
+		word, count, found := strings.Cut(line, "\t")
+		n := 1
+		if found {
+			if n, err = strconv.Atoi(strings.TrimSpace(count)); err != nil {
+				return nil, nil, fmt.Errorf("invalid frequency on line %d: %v", i+1, err)
+			}
+		}
+		words = append(words, word)
+		freq[word] += n
+	}
+
+	return MakePrefixTree(words), freq, nil
+}
+
+// LoadStopwords is for loading a stopword list from file
+func LoadStopwords(path string) (map[string]struct{}, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
 
-	b, err := ioutil.ReadAll(f)
+	lines, err := readLines(f)
 	if err != nil {
 		return nil, err
 	}
 
-	scanner := bufio.NewScanner(bytes.NewReader(b))
+	stopwords := make(map[string]struct{}, len(lines))
+	for _, line := range lines {
+		stopwords[line] = struct{}{}
+	}
+
+	return stopwords, nil
+}
+
+// decompress returns a reader of the decompressed content of r when it is
+// gzip compressed, otherwise of r as is
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil || magic[0] != 0x1f || magic[1] != 0x8b {
+		return br, nil
+	}
+
+	return gzip.NewReader(br)
+}
+
+// bom is the UTF-8 byte order mark some editors write at the start of a file
+var bom = []byte("\ufeff")
+
+// readLines reads all non-empty lines from r
+func readLines(r io.Reader) ([]string, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(bytes.TrimPrefix(b, bom)))
 
 	lines := make([]string, 0)
 	for scanner.Scan() {
@@ -86,28 +314,121 @@ func LoadDict(path string) (PrefixTree, error) {
 		}
 	}
 
+	return lines, nil
+}
+
+// MakeDict builds a dictionary from a word list, empty words are skipped
+// like empty lines of a dictionary file
+func MakeDict(words []string) PrefixTree {
+	lines := make([]string, 0, len(words))
+	for _, word := range words {
+		if len(word) != 0 {
+			lines = append(lines, word)
+		}
+	}
+
+	return MakePrefixTree(lines)
+}
+
+// MakeDictStrict builds a dictionary like MakeDict, ErrEmptyDict is
+// returned when words has no non-empty word
+func MakeDictStrict(words []string) (PrefixTree, error) {
+	dict := MakeDict(words)
+	if len(dict) == 0 {
+		return nil, ErrEmptyDict
+	}
+	return dict, nil
+}
+
+// MakePrefixTree builds a prefix tree from a word list
+func MakePrefixTree(words []string) PrefixTree {
+	lines := make([]string, len(words))
+	copy(lines, words)
 	sort.Strings(lines)
 
-	tab := make(PrefixTree)
-	for i, line := range lines {
-		rowNo := 0
-		runes := []rune(line)
-		len := len(runes)
+	b := NewDictBuilder()
+	for _, line := range lines {
+		b.AddWord(line)
+	}
+
+	return b.Build()
+}
 
-		for j, ch := range runes {
-			isFinal := ((j + 1) == len)
-			node := PrefixTreeNode{rowNo, j, ch}
+// Lookup returns the child of node nodeID at offset for ch
+func (t PrefixTree) Lookup(nodeID, offset int, ch rune) (PrefixTreePointer, bool) {
+	child, found := t[PrefixTreeNode{nodeID, offset, ch}]
+	return child, found
+}
 
-			if child, found := tab[node]; !found {
-				tab[node] = PrefixTreePointer{i, isFinal}
-				rowNo = i
-			} else {
-				rowNo = child.ChildID
+// IsWordStart reports whether any dictionary word begins with ch
+func (t PrefixTree) IsWordStart(ch rune) bool {
+	_, found := t[PrefixTreeNode{0, 0, ch}]
+	return found
+}
+
+// firstRunes returns the set of runes that can begin a dictionary word
+func (t PrefixTree) firstRunes() map[rune]bool {
+	starts := make(map[rune]bool)
+	for node := range t {
+		if node.NodeID == 0 && node.Offset == 0 {
+			starts[node.Ch] = true
+		}
+	}
+	return starts
+}
+
+// LongestMatchAt returns the rune length of the longest dictionary word
+// starting at runes[i], or 0 when no word starts there
+func (t PrefixTree) LongestMatchAt(runes []rune, i int) int {
+	longest := 0
+	nodeID := 0
+	for j := i; j < len(runes); j++ {
+		child, found := t[PrefixTreeNode{nodeID, j - i, runes[j]}]
+		if !found {
+			break
+		}
+		if child.IsFinal {
+			longest = j - i + 1
+		}
+		nodeID = child.ChildID
+	}
+
+	return longest
+}
+
+// NodeCount returns the number of (NodeID, Offset, Ch) entries of the
+// prefix tree, one for every character edge
+func (t PrefixTree) NodeCount() int {
+	return len(t)
+}
+
+// Len returns the number of words of the prefix tree, words sharing a
+// minimized suffix are each counted
+func (t PrefixTree) Len() int {
+	children := make(map[trieState][]PrefixTreePointer)
+	for node, child := range t {
+		state := trieState{node.NodeID, node.Offset}
+		children[state] = append(children[state], child)
+	}
+
+	counts := make(map[trieState]int)
+	var count func(state trieState) int
+	count = func(state trieState) int {
+		if n, ok := counts[state]; ok {
+			return n
+		}
+		n := 0
+		for _, child := range children[state] {
+			if child.IsFinal {
+				n++
 			}
+			n += count(trieState{child.ChildID, state.Offset + 1})
 		}
+		counts[state] = n
+		return n
 	}
 
-	return tab, nil
+	return count(trieState{0, 0})
 }
 
 // LoadDefaultDict - loading default Thai dictionary
@@ -116,15 +437,88 @@ func LoadDefaultDict() (PrefixTree, error) {
 	return LoadDict(path.Join(path.Dir(filename), "tdict-std.txt"))
 }
 
+var (
+	defaultDict     PrefixTree
+	defaultDictErr  error
+	defaultDictOnce sync.Once
+)
+
+// DefaultDict returns the default Thai dictionary, it is loaded on the
+// first call and the same prefix tree is shared by every later call. The
+// cached tree stays in memory for the life of the program.
+func DefaultDict() (PrefixTree, error) {
+	defaultDictOnce.Do(func() {
+		defaultDict, defaultDictErr = LoadDefaultDict()
+	})
+	return defaultDict, defaultDictErr
+}
+
+// PreloadDefaultDict loads the default dictionary of DefaultDict ahead of
+// the first request
+func PreloadDefaultDict() error {
+	_, err := DefaultDict()
+	return err
+}
+
 func main() {
 	// p := profile.Start(profile.CPUProfile, profile.ProfilePath("."))
 	// defer p.Stop()
 
-	var dictPath string
+	var (
+		dictPath   string
+		columns    string
+		fieldDelim string
+		stopPath   string
+		records    bool
+		sentSep    string
+		surfaceSep string
+		loose      bool
+		best       bool
+		markup     bool
+		eos        string
+	)
 	flag.StringVar(&dictPath, "dix", "", "Dictionary path")
+	flag.StringVar(&columns, "cols", "", "Comma-separated column indexes to segment (default whole line)")
+	flag.StringVar(&fieldDelim, "fs", "\t", "Field delimiter used with -cols")
+	flag.StringVar(&stopPath, "stop", "", "Stopword list path")
+	flag.BoolVar(&records, "records", false, "Write every token as a JSON line record")
+	flag.StringVar(&sentSep, "sent", "", "Sentence separator kept as its own token")
+	flag.BoolVar(&best, "best", false, "Write lines in the BEST corpus format")
+	flag.BoolVar(&loose, "loose", false, "Ignore Thai tone marks in the dictionary and input")
+	flag.StringVar(&eos, "eos", "", "Token written at the end of every line")
+	flag.BoolVar(&markup, "markup", false, "Pass <...> tags through and segment the text between them")
+	flag.StringVar(&surfaceSep, "surface", "", "Write every token with its normalized form joined by this separator")
 	flag.Parse()
 
-	NewSegmenterWorker(dictPath).Run()
+	w := NewSegmenterWorker(dictPath)
+	w.TokenRecords = records
+	w.SentenceSep = sentSep
+	w.SurfaceSep = surfaceSep
+	w.BEST = best
+	w.Markup = markup
+	w.EOS = eos
+	if loose {
+		w.LooseMatch = true
+		w.SwapDict(w.dict.Loose())
+	}
+	if columns != "" {
+		for _, col := range strings.Split(columns, ",") {
+			c, err := strconv.Atoi(strings.TrimSpace(col))
+			if err != nil {
+				log.Fatal("invalid column index:", err)
+			}
+			w.Columns = append(w.Columns, c)
+		}
+		w.FieldDelim = fieldDelim
+	}
+	if stopPath != "" {
+		stopwords, err := LoadStopwords(stopPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		w.Stopwords = stopwords
+	}
+	w.Run()
 }
 
 func NewSegmenterWorker(dictPath string) *SegmenterWorker {
@@ -133,43 +527,131 @@ func NewSegmenterWorker(dictPath string) *SegmenterWorker {
 		log.Fatal(err)
 	}
 
+	return NewSegmenterWorkerFromDict(dict)
+}
+
+// NewSegmenterWorkerFromDict creates a worker segmenting with dict
+func NewSegmenterWorkerFromDict(dict PrefixTree) *SegmenterWorker {
 	return &SegmenterWorker{
 		dict: dict,
 	}
 }
 
+// NewSegmenterWorkerFromWords creates a worker segmenting with an in-memory
+// word list
+func NewSegmenterWorkerFromWords(words []string) *SegmenterWorker {
+	return NewSegmenterWorkerFromDict(MakeDict(words))
+}
+
 type SegmenterWorker struct {
 	dict PrefixTree
 
+	// Columns lists the field indexes to segment when FieldDelim is set,
+	// other fields are passed through untouched
+	Columns    []int
+	FieldDelim string
+
+	// Stopwords are removed from the segmented output
+	Stopwords map[string]struct{}
+
+	// KeepDelim treats | in the input as an existing token boundary, so
+	// segmented output can be segmented again without change
+	KeepDelim bool
+
+	// SentenceSep separates sentences of a line, see Segmenter.SentenceSep
+	SentenceSep string
+
+	// LooseMatch ignores Thai tone marks, see Segmenter.LooseMatch
+	LooseMatch bool
+
+	// UnicodeSpaces treats zero-width and narrow spaces as Space, see
+	// Segmenter.UnicodeSpaces
+	UnicodeSpaces bool
+
+	// SurfaceSep writes every token followed by SurfaceSep and its
+	// Normalize form when set
+	SurfaceSep string
+
+	// TokenRecords writes every token as its own JSON line record with
+	// its line number, token index and start offset instead of delimited lines
+	TokenRecords bool
+
+	// ReverseTokens writes the tokens of every line in reverse order, the
+	// text of every token is kept
+	ReverseTokens bool
+
+	// EOS is written as the last token of every line when set, with
+	// Columns as the last token of every segmented column
+	EOS string
+
+	// HTML writes every line as HTML with each token wrapped in a span
+	HTML bool
+
+	// Markup passes <...> tags of the input through as tokens and segments
+	// only the text between them
+	Markup bool
+
+	// BEST writes every line in the BEST corpus format of FormatBEST
+	BEST bool
+
+	// OrderedTokens makes TokenChannel send lines in input order
+	OrderedTokens bool
+
+	// MaxInputBytes makes RunIO fail without segmenting when the input is
+	// larger than this many bytes
+	MaxInputBytes int64
+
+	// OutputBufferSize is the size in bytes of the buffer RunIO writes out
+	// through, the bufio default of 4096 when 0
+	OutputBufferSize int
+
+	// LineTimeout limits the time segmenting one line, a line taking
+	// longer is written raw and reported by TimedOut
+	LineTimeout time.Duration
+	timedOut    []int
+	timeoutMu   sync.Mutex
+
+	// Comparator is the EdgeComparator of the worker Segmenters
+	Comparator EdgeComparator
+
+	// swapped holds the *dictRef set by SwapDict
+	swapped atomic.Value
+
 	lineInputCh chan LineInput
-	result      Result
+	result      *Result
 	done        chan struct{}
 
-	wg   sync.WaitGroup
-	once sync.Once
+	wg sync.WaitGroup
 }
 
 func (w *SegmenterWorker) StartWorker() {
 	w.lineInputCh = make(chan LineInput, runtime.NumCPU())
-	w.result = Result{
-		out:    bufio.NewWriter(os.Stdout),
+	w.result = &Result{
 		result: make(map[int]string),
 	}
 	w.done = make(chan struct{})
 
+	// the workers keep the channels and result of this start, a later
+	// RunIO starts new ones
+	lineInputCh, results, done := w.lineInputCh, w.result, w.done
 	for wc := 0; wc < runtime.NumCPU(); wc++ {
 		go func() {
-			sm := Segmenter{
-				dict: w.dict,
-			}
+			ref := w.dictRef()
+			sm := w.newSegmenter()
+			sm.SetDict(ref.dict)
 
 			for {
 				select {
-				case lineInput := <-w.lineInputCh:
-					result := strings.Join(sm.Segment(lineInput.textRunes), "|") + "\n"
-					w.result.Set(lineInput.lineNo, result)
+				case lineInput := <-lineInputCh:
+					// pick up a dictionary swapped since the last line
+					if current := w.dictRef(); current != ref {
+						ref = current
+						sm.SetDict(ref.dict)
+					}
+					result := w.formatLineTimeout(sm, lineInput)
+					results.Set(lineInput.lineNo, result)
 					w.wg.Done()
-				case <-w.done:
+				case <-done:
 					return
 				}
 			}
@@ -178,15 +660,172 @@ func (w *SegmenterWorker) StartWorker() {
 	}
 }
 
+// dictRef is a dictionary set by SwapDict
+type dictRef struct {
+	dict PrefixTree
+}
+
+// SwapDict replaces the dictionary of the worker. Lines already being
+// segmented finish with the old dictionary, following lines use d.
+func (w *SegmenterWorker) SwapDict(d PrefixTree) {
+	w.swapped.Store(&dictRef{d})
+}
+
+func (w *SegmenterWorker) dictRef() *dictRef {
+	if ref, ok := w.swapped.Load().(*dictRef); ok {
+		return ref
+	}
+	w.swapped.CompareAndSwap(nil, &dictRef{w.dict})
+	return w.swapped.Load().(*dictRef)
+}
+
+// newSegmenter creates a Segmenter with the worker options
+func (w *SegmenterWorker) newSegmenter() *Segmenter {
+	sm := NewSegmenter(w.dictRef().dict)
+	sm.Stopwords = w.Stopwords
+	sm.SentenceSep = w.SentenceSep
+	sm.Comparator = w.Comparator
+	sm.LooseMatch = w.LooseMatch
+	sm.UnicodeSpaces = w.UnicodeSpaces
+	if w.KeepDelim {
+		sm.Boundary = '|'
+	}
+	return sm
+}
+
+// formatLineTimeout formats a line like formatLine. When segmenting takes
+// longer than LineTimeout it is stopped and the raw line is returned.
+func (w *SegmenterWorker) formatLineTimeout(sm *Segmenter, lineInput LineInput) string {
+	if w.LineTimeout <= 0 {
+		return w.formatLine(sm, lineInput)
+	}
+
+	sm.SetDeadline(time.Now().Add(w.LineTimeout))
+	defer sm.SetDeadline(time.Time{})
+
+	result := w.formatLine(sm, lineInput)
+	if !sm.DeadlineExceeded() {
+		return result
+	}
+
+	w.timeoutMu.Lock()
+	w.timedOut = append(w.timedOut, lineInput.lineNo)
+	w.timeoutMu.Unlock()
+	return string(lineInput.textRunes) + "\n"
+}
+
+// TimedOut returns the sorted numbers of the lines written raw because
+// they exceeded LineTimeout
+func (w *SegmenterWorker) TimedOut() []int {
+	w.timeoutMu.Lock()
+	defer w.timeoutMu.Unlock()
+
+	lines := append([]int(nil), w.timedOut...)
+	sort.Ints(lines)
+	return lines
+}
+
+// formatLine segments a line of input and formats it for output, every
+// output mode writes the tokens of segmentTokens
+func (w *SegmenterWorker) formatLine(sm *Segmenter, lineInput LineInput) string {
+	if w.TokenRecords {
+		return w.tokenRecords(sm, lineInput)
+	}
+	if w.HTML {
+		return FormatHTML(w.segmentTokens(sm, lineInput.textRunes)) + "\n"
+	}
+	if w.BEST {
+		return FormatBEST(w.segmentTokens(sm, lineInput.textRunes)) + "\n"
+	}
+	return w.SegmentLine(sm, lineInput.textRunes) + "\n"
+}
+
+// SegmentLine segments a line of input, only the configured columns are
+// segmented when FieldDelim is set
+func (w *SegmenterWorker) SegmentLine(sm *Segmenter, textRunes []rune) string {
+	if w.FieldDelim == "" || len(w.Columns) == 0 {
+		return strings.Join(w.segmentTokens(sm, textRunes), "|")
+	}
+
+	fields := strings.Split(string(textRunes), w.FieldDelim)
+	for _, c := range w.Columns {
+		if c < 0 || c >= len(fields) {
+			continue
+		}
+		fields[c] = strings.Join(w.segmentTokens(sm, []rune(fields[c])), "|")
+	}
+
+	return strings.Join(fields, w.FieldDelim)
+}
+
+// segmentTokens segments textRunes into the output tokens of the worker
+func (w *SegmenterWorker) segmentTokens(sm *Segmenter, textRunes []rune) []string {
+	tokens, _ := w.segmentTokenOffsets(sm, textRunes, false)
+	return tokens
+}
+
+// segmentTokenOffsets segments textRunes into the output tokens of the
+// worker. With withOffsets set it also returns the rune offset of every
+// token in textRunes, see tokenOffsets, and len(textRunes) for EOS.
+func (w *SegmenterWorker) segmentTokenOffsets(sm *Segmenter, textRunes []rune, withOffsets bool) ([]string, []int) {
+	var tokens []string
+	if w.Markup {
+		tokens = sm.SegmentMarkup(textRunes)
+	} else {
+		tokens = sm.Segment(textRunes)
+	}
+
+	var offsets []int
+	if withOffsets {
+		offsets = tokenOffsets(textRunes, tokens)
+	}
+	if w.SurfaceSep != "" {
+		tokens = surfaceForms(tokens, w.SurfaceSep)
+	}
+	if w.ReverseTokens {
+		for i, j := 0, len(tokens)-1; i < j; i, j = i+1, j-1 {
+			tokens[i], tokens[j] = tokens[j], tokens[i]
+			if withOffsets {
+				offsets[i], offsets[j] = offsets[j], offsets[i]
+			}
+		}
+	}
+	if w.EOS != "" {
+		tokens = append(tokens, w.EOS)
+		if withOffsets {
+			offsets = append(offsets, len(textRunes))
+		}
+	}
+	return tokens, offsets
+}
+
 func (w *SegmenterWorker) Run() {
-	w.once.Do(w.StartWorker)
+	if err := w.RunIO(os.Stdin, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
 
-	b, err := ioutil.ReadAll(os.Stdin)
+// RunIO segments every line read from in and writes the result to out.
+// Every input line is written as one output line, an empty line stays
+// empty, so the output aligns with the input except with TokenRecords.
+// Every call starts its own workers, RunIO may be called again once it
+// returned but not concurrently.
+func (w *SegmenterWorker) RunIO(in io.Reader, out io.Writer) error {
+	if w.MaxInputBytes > 0 {
+		in = io.LimitReader(in, w.MaxInputBytes+1)
+	}
+	b, err := ioutil.ReadAll(in)
 	if err != nil {
-		log.Fatal("could not read input:", err)
+		return fmt.Errorf("could not read input: %v", err)
 	}
+	if w.MaxInputBytes > 0 && int64(len(b)) > w.MaxInputBytes {
+		return fmt.Errorf("input is larger than the limit of %d bytes", w.MaxInputBytes)
+	}
+
+	w.StartWorker()
+	w.result.out = bufio.NewWriterSize(out, w.OutputBufferSize)
 
-	scanner := bufio.NewScanner(bytes.NewReader(b))
+	scanner := bufio.NewScanner(bytes.NewReader(bytes.TrimPrefix(b, bom)))
 
 	i := 0
 	for scanner.Scan() {
@@ -204,7 +843,7 @@ func (w *SegmenterWorker) Run() {
 
 	w.wg.Wait()
 	close(w.done)
-	w.result.WriteOut()
+	return w.result.WriteOut()
 }
 
 type Result struct {
@@ -220,38 +859,538 @@ func (r *Result) Set(lineNo int, line string) {
 	r.mu.Unlock()
 }
 
-func (r *Result) WriteOut() {
+func (r *Result) WriteOut() error {
 	for i := 0; i < len(r.result); i++ {
 		r.mu.Lock()
 		r.out.WriteString(r.result[i])
 		r.mu.Unlock()
 	}
-	r.out.Flush()
+	return r.out.Flush()
 }
 
 type Segmenter struct {
 	dict     PrefixTree
+	starts   map[rune]bool
+	chain    DictChain
+	fallback *Segmenter
+	ranks    map[string]int
 	path     []Edge
+	ties     []bool
+	nodes    []int
+	lengths  []int
+	oov      []bool
+	ends     []int
 	pointers []DictBuilderPointer
+
+	// Stopwords are removed from the tokens returned by Segment
+	Stopwords map[string]struct{}
+
+	// Boundary is a rune marking an existing token boundary in the input,
+	// text between Boundary runes is segmented separately and the Boundary
+	// runes are dropped
+	Boundary rune
+
+	// HardBoundaries are ranges of runes never matched in the dictionary,
+	// a run of them is its own token
+	HardBoundaries []*unicode.RangeTable
+
+	// SentenceSep separates sentences of the input, every sentence is
+	// segmented separately and the separator is kept as its own token
+	SentenceSep string
+
+	// FoldLatinMatch splits Latin runs into lower case dictionary words
+	// when the whole run is covered by them, tokens keep the original case
+	FoldLatinMatch bool
+	lower          []rune
+
+	// LatinFold lower cases Latin tokens, other tokens are kept as they are
+	LatinFold bool
+
+	// MergeSingleRunes joins adjacent single rune text tokens into one
+	// token when they form a dictionary word
+	MergeSingleRunes bool
+
+	// SplitCamelCase splits Latin tokens on lower to upper case changes,
+	// so iPhone becomes i and Phone
+	SplitCamelCase bool
+
+	// MaxTokenRunes splits tokens longer than this many runes into pieces
+	// of this many runes when set
+	MaxTokenRunes int
+
+	// Repeat controls how the repetition mark ๆ is emitted
+	Repeat RepeatMode
+
+	// SpaceBoundaries never matches dictionary words across a space, even
+	// words of the dictionary containing spaces
+	SpaceBoundaries bool
+
+	// MixedWords lets dictionary words continue into Latin, Number and
+	// Symbol runs, so words like โควิด-19 match as one token
+	MixedWords bool
+
+	// UnicodeSpaces treats zero-width and narrow spaces as Space, also in
+	// the space checks of the output and statistics
+	UnicodeSpaces bool
+
+	// Classifiers are tried in order before the built-in classification of
+	// every rune, the first one reporting true decides its WordType. Text
+	// runes are matched against the dictionary.
+	Classifiers []RuneClassifier
+
+	// Joiners are runes like _ and - kept inside a Latin or text run when
+	// the runes on both sides of them belong to that run
+	Joiners string
+
+	// GroupUnknown groups the unknown characters since the last known word
+	// into one unknown token, without it every unknown character is its own
+	// token. NewSegmenter sets it.
+	GroupUnknown bool
+
+	// UnknownChunk splits grouped unknown characters into tokens of at most
+	// this many runes when set
+	UnknownChunk int
+
+	// FoldFullWidth classifies and matches full-width ASCII forms like Ａ
+	// and １ as their ASCII runes, tokens keep the original text
+	FoldFullWidth bool
+	wide          []rune
+
+	// LooseMatch ignores Thai tone marks of the input, the dictionary should
+	// be made Loose
+	LooseMatch bool
+
+	// MarkUnknown wraps tokens not matched in the dictionary like <UNK:token>
+	MarkUnknown bool
+
+	// CollapseSpaces makes SegmentToSpaced drop whitespace tokens, the
+	// joining space stands in for them
+	CollapseSpaces bool
+
+	// LengthBonus lowers the cost of a path by this much times the squared
+	// rune length of every dictionary word on it, so of paths with as many
+	// unknown words the one with longer words wins. Below 1/n² of the
+	// longest word length n it only breaks ties of the word count, above
+	// that a long word may win over a path of fewer words. Unknown words
+	// get no bonus.
+	LengthBonus float64
+
+	// Comparator chooses between edges of dictionary words instead of the
+	// unknown and word counts. LengthBonus only changes the cost compared
+	// without a Comparator, so it has no effect with one.
+	Comparator EdgeComparator
+
+	// UnkPenalty charges every unknown character the penalty of its script
+	// instead of one per unknown word. Latin runs not covered by dictionary
+	// words count as unknown and are charged per character too. They are
+	// only matched against the dictionary, and so only split, when
+	// FoldLatinMatch is set.
+	UnkPenalty *ScriptPenalty
+
+	deadline time.Time
+	exceeded bool
+}
+
+// SetDeadline makes BuildPath stop at t, the rest of its line is then one
+// unknown word. The zero time means no deadline.
+func (sm *Segmenter) SetDeadline(t time.Time) {
+	sm.deadline = t
+	sm.exceeded = false
+}
+
+// DeadlineExceeded reports whether a BuildPath stopped at the deadline since
+// it was last set
+func (sm *Segmenter) DeadlineExceeded() bool {
+	return sm.exceeded
+}
+
+// SetDict replaces the dictionary of the Segmenter
+func (sm *Segmenter) SetDict(dict PrefixTree) {
+	sm.dict = dict
+	sm.chain = nil
+	sm.fallback = nil
+	sm.starts = dict.firstRunes()
+}
+
+// NewSegmenter creates a Segmenter for the dictionary
+func NewSegmenter(dict PrefixTree) *Segmenter {
+	return &Segmenter{
+		dict:         dict,
+		starts:       dict.firstRunes(),
+		GroupUnknown: true,
+	}
+}
+
+// NewSegmenterFromWords creates a Segmenter for a dictionary of words
+func NewSegmenterFromWords(words []string) *Segmenter {
+	return NewSegmenter(MakeDict(words))
+}
+
+// Segment segments text with dict on a new Segmenter. Reuse a Segmenter
+// for repeated calls, it keeps its buffers between calls.
+func Segment(dict PrefixTree, text string) []string {
+	return NewSegmenter(dict).Segment([]rune(text))
 }
 
 func (sm *Segmenter) Segment(textRunes []rune) []string {
+	return sm.SegmentAppend(nil, textRunes)
+}
+
+// SegmentAppend segments textRunes like Segment and appends the tokens to
+// dst, which is reset to length 0 first. Passing the previous result as
+// dst reuses its backing array.
+func (sm *Segmenter) SegmentAppend(dst []string, textRunes []rune) []string {
+	if dst == nil {
+		dst = make([]string, 0)
+	}
+	return sm.postProcess(sm.appendTokens(dst[:0], textRunes))
+}
+
+// SegmentToSpaced segments text and joins the tokens with a single space
+// for display. Whitespace tokens are joined like the others unless
+// CollapseSpaces is set.
+func (sm *Segmenter) SegmentToSpaced(text string) string {
+	var b strings.Builder
+	for _, token := range sm.Segment([]rune(text)) {
+		if sm.CollapseSpaces && sm.isBlankToken(token) {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(token)
+	}
+	return b.String()
+}
+
+// appendTokens segments textRunes without the output filters and appends
+// the tokens to dst
+func (sm *Segmenter) appendTokens(dst []string, textRunes []rune) []string {
+	sm.eachSpan(textRunes, func(s, e, at int) {
+		dst = append(dst, sm.token(textRunes, s, e, at))
+	})
+	return dst
+}
+
+// token returns the token textRunes[s:e] whose edge is at, marked when
+// MarkUnknown is set and it is unknown
+func (sm *Segmenter) token(textRunes []rune, s, e, at int) string {
+	if sm.MarkUnknown && at >= 0 && sm.oov[at] {
+		return "<UNK:" + string(textRunes[s:e]) + ">"
+	}
+	return string(textRunes[s:e])
+}
+
+// eachSpan segments textRunes the way Segment does before its output
+// filters and calls yield with every token in order. The token is
+// textRunes[s:e] and at is the position of its edge in the path and the
+// other per-position slices, or -1 for a SentenceSep token, which has no
+// edge. yield must not segment with sm.
+func (sm *Segmenter) eachSpan(textRunes []rune, yield func(s, e, at int)) {
+	if sm.SentenceSep == "" {
+		sm.eachSentenceSpan(textRunes, 0, yield)
+		return
+	}
+
+	// the sentences between SentenceSep are segmented separately
+	sep := []rune(sm.SentenceSep)
+	start := 0
+	for i := 0; i+len(sep) <= len(textRunes); i++ {
+		if !hasRunePrefix(textRunes[i:], sep) {
+			continue
+		}
+		sm.eachSentenceSpan(textRunes[start:i], start, yield)
+		yield(i, i+len(sep), -1)
+		start = i + len(sep)
+		i = start - 1
+	}
+	sm.eachSentenceSpan(textRunes[start:], start, yield)
+}
+
+// eachSentenceSpan yields the tokens of sentence, which starts at offset
+// base of the text. The pieces between Boundary runes are segmented
+// separately.
+func (sm *Segmenter) eachSentenceSpan(sentence []rune, base int, yield func(s, e, at int)) {
+	if sm.Boundary == 0 {
+		sm.eachPieceSpan(sentence, base, yield)
+		return
+	}
+
+	start := 0
+	for i := 0; i <= len(sentence); i++ {
+		if i < len(sentence) && sentence[i] != sm.Boundary {
+			continue
+		}
+		sm.eachPieceSpan(sentence[start:i], base+start, yield)
+		start = i + 1
+	}
+}
+
+// eachPieceSpan yields the tokens of piece, which starts at offset base of
+// the text, with tone marks stripped when LooseMatch is set
+func (sm *Segmenter) eachPieceSpan(piece []rune, base int, yield func(s, e, at int)) {
+	if sm.LooseMatch {
+		sm.eachLooseSpan(piece, base, yield)
+		return
+	}
+	sm.eachPathSpan(piece, base, yield)
+}
+
+// eachPathSpan builds the path of piece, which starts at offset base of the
+// text, and yields its tokens
+func (sm *Segmenter) eachPathSpan(piece []rune, base int, yield func(s, e, at int)) {
+	sm.BuildPath(piece)
+	for _, e := range sm.pathEnds(len(piece)) {
+		yield(base+sm.path[e].S, base+e, e)
+	}
+}
+
+// pathEnds returns the end of every token on the path of a line of n runes
+// in order. The slice is reused by the next call.
+func (sm *Segmenter) pathEnds(n int) []int {
+	ends := sm.ends[:0]
+	for e := n; e > 0; e = sm.path[e].S {
+		ends = append(ends, e)
+	}
+	for a, b := 0, len(ends)-1; a < b; a, b = a+1, b-1 {
+		ends[a], ends[b] = ends[b], ends[a]
+	}
+	sm.ends = ends
+
+	return ends
+}
+
+// hasRunePrefix reports whether runes begins with prefix
+func hasRunePrefix(runes, prefix []rune) bool {
+	if len(runes) < len(prefix) {
+		return false
+	}
+	for i, ch := range prefix {
+		if runes[i] != ch {
+			return false
+		}
+	}
+	return true
+}
+
+// SegmentWithPath segments textRunes like Segment and also returns a copy
+// of the edge path, one edge for every position of textRunes plus one. With
+// SentenceSep, Boundary or LooseMatch set textRunes is not one path and the
+// copy is the path of the last piece segmented.
+func (sm *Segmenter) SegmentWithPath(textRunes []rune) ([]string, []Edge) {
+	tokens := sm.Segment(textRunes)
+	path := make([]Edge, len(sm.path))
+	copy(path, sm.path)
+
+	return tokens, path
+}
+
+// CostTable builds the path of textRunes and returns a copy of it, the best
+// edge ending at every position of textRunes plus one
+func (sm *Segmenter) CostTable(textRunes []rune) []Edge {
 	sm.BuildPath(textRunes)
+	path := make([]Edge, len(sm.path))
+	copy(path, sm.path)
 
-	l := len(sm.path)
-	tokens := make([]string, l)
-	e := l - 1
-	i := e
-	s := sm.path[e].S
+	return path
+}
+
+// SegmentOffsets segments textRunes and also returns the rune offset each
+// token starts at. Output filters of Segment are not applied.
+func (sm *Segmenter) SegmentOffsets(textRunes []rune) ([]string, []int) {
+	var (
+		tokens  []string
+		offsets []int
+	)
+	sm.eachSpan(textRunes, func(s, e, at int) {
+		tokens = append(tokens, sm.token(textRunes, s, e, at))
+		offsets = append(offsets, s)
+	})
+
+	return tokens, offsets
+}
+
+// SegmentRange segments runes[start:end] like Segment without copying it
+func (sm *Segmenter) SegmentRange(runes []rune, start, end int) []string {
+	return sm.Segment(runes[start:end:end])
+}
+
+// SegmentRangeOffsets segments runes[start:end] like SegmentOffsets, the
+// offsets are relative to runes
+func (sm *Segmenter) SegmentRangeOffsets(runes []rune, start, end int) ([]string, []int) {
+	tokens, offsets := sm.SegmentOffsets(runes[start:end:end])
+	for i := range offsets {
+		offsets[i] += start
+	}
 
-	for e > 0 {
-		s = sm.path[e].S
-		tokens[i] = string(textRunes[s:e])
-		e = s
-		i--
+	return tokens, offsets
+}
+
+// SegmentWithBoundaries segments runes like Segment without any token
+// crossing the rune offsets of fixed, the text between two fixed offsets is
+// segmented on its own. Offsets out of range of runes are ignored.
+func (sm *Segmenter) SegmentWithBoundaries(runes []rune, fixed []int) []string {
+	bounds := append(make([]int, 0, len(fixed)+1), fixed...)
+	sort.Ints(bounds)
+	bounds = append(bounds, len(runes))
+
+	tokens := make([]string, 0)
+	start := 0
+	for _, end := range bounds {
+		if end <= start || end > len(runes) {
+			continue
+		}
+
+		tokens = sm.appendTokens(tokens, runes[start:end:end])
+		start = end
+	}
+
+	return sm.postProcess(tokens)
+}
+
+// SegmentAmbiguous segments textRunes like Segment and also reports for
+// each token whether its edge was chosen from equally scored candidates
+func (sm *Segmenter) SegmentAmbiguous(textRunes []rune) ([]string, []bool) {
+	var (
+		tokens    []string
+		ambiguous []bool
+	)
+	sm.eachSpan(textRunes, func(s, e, at int) {
+		tokens = append(tokens, sm.token(textRunes, s, e, at))
+		ambiguous = append(ambiguous, at >= 0 && sm.ties[at])
+	})
+
+	return tokens, ambiguous
+}
+
+// SegmentUnknown segments textRunes and also reports for each token whether
+// it is an unknown word not matched in the dictionary. Output filters of
+// Segment are not applied.
+func (sm *Segmenter) SegmentUnknown(textRunes []rune) ([]string, []bool) {
+	var (
+		tokens  []string
+		unknown []bool
+	)
+	sm.eachSpan(textRunes, func(s, e, at int) {
+		tokens = append(tokens, sm.token(textRunes, s, e, at))
+		unknown = append(unknown, at >= 0 && sm.oov[at])
+	})
+
+	return tokens, unknown
+}
+
+// SegmentUnkDeltas segments textRunes and also reports for each token how
+// much it adds to the UnkCount of the path, 1 for an unknown word unless
+// UnkPenalty is set. Output filters of Segment are not applied.
+func (sm *Segmenter) SegmentUnkDeltas(textRunes []rune) ([]string, []int) {
+	var (
+		tokens []string
+		deltas []int
+	)
+	sm.eachSpan(textRunes, func(s, e, at int) {
+		tokens = append(tokens, sm.token(textRunes, s, e, at))
+		delta := 0
+		if at >= 0 {
+			delta = sm.path[at].UnkCount - sm.path[sm.path[at].S].UnkCount
+		}
+		deltas = append(deltas, delta)
+	})
+
+	return tokens, deltas
+}
+
+// Boundaries segments textRunes and returns the rune offsets of the token
+// boundaries in order, 0 and len(textRunes) included. Both sides of a
+// dropped Boundary rune are boundaries. Output filters of Segment are not
+// applied.
+func (sm *Segmenter) Boundaries(textRunes []rune) []int {
+	bounds := []int{0}
+	sm.eachSpan(textRunes, func(s, e, at int) {
+		if s != bounds[len(bounds)-1] {
+			bounds = append(bounds, s)
+		}
+		bounds = append(bounds, e)
+	})
+	if bounds[len(bounds)-1] != len(textRunes) {
+		bounds = append(bounds, len(textRunes))
 	}
 
-	return tokens[i+1:]
+	return bounds
+}
+
+// BoundaryAt reports whether segmenting textRunes puts a token boundary
+// between textRunes[i-1] and textRunes[i]. The start and end of textRunes
+// are boundaries. Output filters of Segment are not applied.
+func (sm *Segmenter) BoundaryAt(textRunes []rune, i int) bool {
+	if i < 0 || i > len(textRunes) {
+		return false
+	}
+
+	bounds := sm.Boundaries(textRunes)
+	j := sort.SearchInts(bounds, i)
+	return j < len(bounds) && bounds[j] == i
+}
+
+// BIOTags segments textRunes and returns a tag for every rune, 'B' for the
+// first rune of a token, 'I' for the others and 'O' for a dropped Boundary
+// rune. Output filters of Segment are not applied.
+func (sm *Segmenter) BIOTags(textRunes []rune) []byte {
+	tags := make([]byte, len(textRunes))
+	for i := range tags {
+		tags[i] = 'O'
+	}
+	sm.eachSpan(textRunes, func(s, e, at int) {
+		tags[s] = 'B'
+		for i := s + 1; i < e; i++ {
+			tags[i] = 'I'
+		}
+	})
+
+	return tags
+}
+
+// SegmentMatchLengths segments textRunes and also reports for each token
+// the rune length of its dictionary match, 0 when the token is not a
+// dictionary word. Output filters of Segment are not applied.
+func (sm *Segmenter) SegmentMatchLengths(textRunes []rune) ([]string, []int) {
+	var (
+		tokens  []string
+		lengths []int
+	)
+	sm.eachSpan(textRunes, func(s, e, at int) {
+		tokens = append(tokens, sm.token(textRunes, s, e, at))
+		n := 0
+		if at >= 0 && sm.nodes[at] != UnknownNodeID {
+			n = at - sm.path[at].S
+		}
+		lengths = append(lengths, n)
+	})
+
+	return tokens, lengths
+}
+
+// UnknownNodeID is the node ID reported for tokens not matched in the
+// dictionary
+const UnknownNodeID = -1
+
+// SegmentNodeIDs segments textRunes like Segment and also reports for each
+// token the ChildID of the dictionary node its word ended at, or
+// UnknownNodeID when the token is not a dictionary word
+func (sm *Segmenter) SegmentNodeIDs(textRunes []rune) ([]string, []int) {
+	var (
+		tokens  []string
+		nodeIDs []int
+	)
+	sm.eachSpan(textRunes, func(s, e, at int) {
+		tokens = append(tokens, sm.token(textRunes, s, e, at))
+		nodeID := UnknownNodeID
+		if at >= 0 {
+			nodeID = sm.nodes[at]
+		}
+		nodeIDs = append(nodeIDs, nodeID)
+	})
+
+	return tokens, nodeIDs
 }
 
 type NullEdge struct {
@@ -264,13 +1403,19 @@ func (ne *NullEdge) Set(e Edge) {
 	ne.Valid = true
 }
 
+// BuildPath finds the best edge ending at every position of line, preferring
+// the fewest unknown words, then the fewest words. Edges of equal cost are
+// broken by preferring the longer word.
 func (sm *Segmenter) BuildPath(line []rune) {
 	var (
-		bestEdge NullEdge
+		bestEdge edgeCandidate
 		length   int
 		word     Word
 	)
 
+	if sm.FoldFullWidth {
+		line = sm.foldFullWidth(line)
+	}
 	length = len(line)
 
 	if sm.path == nil {
@@ -282,106 +1427,500 @@ func (sm *Segmenter) BuildPath(line []rune) {
 		}
 	}
 
+	if cap(sm.ties) < length+1 {
+		sm.ties = make([]bool, length+1)
+		sm.nodes = make([]int, length+1)
+		sm.lengths = make([]int, length+1)
+		sm.oov = make([]bool, length+1)
+	} else {
+		sm.ties = sm.ties[:length+1]
+		sm.nodes = sm.nodes[:length+1]
+		sm.lengths = sm.lengths[:length+1]
+		sm.oov = sm.oov[:length+1]
+	}
+	for i := range sm.ties {
+		sm.ties[i] = false
+		sm.nodes[i] = UnknownNodeID
+		sm.lengths[i] = 0
+		sm.oov[i] = false
+	}
+
 	if sm.pointers != nil {
 		sm.pointers = sm.pointers[:0]
 	}
 
+	if sm.starts == nil {
+		sm.starts = sm.dict.firstRunes()
+	}
+
 	word.Path = sm.path
 
 	for i, ch := range line {
-		bestEdge = NullEdge{}
+		if !sm.deadline.IsZero() && time.Now().After(sm.deadline) {
+			sm.exceeded = true
+			sm.path[length] = Edge{
+				S:         i,
+				WordCount: sm.path[i].WordCount + 1,
+				UnkCount:  sm.path[i].UnkCount + 1,
+			}
+			sm.oov[length] = true
+			return
+		}
 
-		switch {
+		bestEdge = edgeCandidate{NodeID: UnknownNodeID, comparator: sm.Comparator}
+
+		switch runType := sm.runType(line, i, word.Type); {
 		// Check Edge type should be one of this
-		// Latin, Space, Dict, Unknow
-		case IsLatin(ch):
-			// check end of space because current is not space
-			// Replace last edge with space edge type
-			if word.Type == Space {
-				word.AppendEdgeAt(i)
+		// Latin, Space, Symbol, Number, Dict, Unknow
+		case runType != Text:
+			// check end of another run type because current is not that type
+			// Replace last edge with the edge of that run type
+			if word.Type != runType && word.Type != Text && word.Type != Unknow {
+				sm.appendRunAt(&word, line, i)
 			}
 
-			if word.Type != Latin {
+			if word.Type != runType {
 				word.Start = i
-				word.Type = Latin
+				word.Type = runType
 			}
 
-			// check end of latin because last ch
-			if i == length-1 {
-				bestEdge.Set(word.GetEdge())
-			}
-
-		case IsSpace(ch):
-			// check end of latin because current is not latin
-			// Replace last edge with latin edge type
-			if word.Type == Latin {
-				word.AppendEdgeAt(i)
+			// dictionary words may contain spaces, keep only the pointers
+			// continuing with this space
+			if runType == Space && sm.SpaceBoundaries {
+				sm.pointers = sm.pointers[:0]
+			} else if runType == Space || sm.MixedWords {
+				sm.advancePointers(ch)
+			} else {
+				sm.pointers = sm.pointers[:0]
 			}
-
-			if word.Type != Space {
-				word.Start = i
-				word.Type = Space
+			if sm.MixedWords {
+				sm.considerPointers(&bestEdge, line, i)
 			}
 
-			// check end of space because last ch
+			// check end of run because last ch
 			if i == length-1 {
-				bestEdge.Set(word.GetEdge())
+				if runType == Latin && sm.FoldLatinMatch && sm.matchLatin(line, word.Start, length) {
+					word.Type = Unknow
+					bestEdge = edgeCandidate{NodeID: sm.nodes[length]}
+					bestEdge.Set(sm.path[length])
+				} else if run := sm.runEdge(&word, line, length); !bestEdge.Valid || !betterThanRun(bestEdge.Edge, run) {
+					bestEdge = edgeCandidate{NodeID: UnknownNodeID}
+					bestEdge.Set(run)
+				}
 			}
+
 		default:
-			// check end of latin or end of space because current is not latin or space
-			if word.Type == Space || word.Type == Latin {
-				word.AppendEdgeAt(i)
+			// check end of latin, space, symbol or number because current is none of them
+			if word.Type != Text && word.Type != Unknow {
+				sm.appendRunAt(&word, line, i)
 			}
 
 			word.Type = Text
 
-			sm.pointers = append(sm.pointers, DictBuilderPointer{})
-			newIndex := 0
-			for j, _ := range sm.pointers {
-				p := sm.pointers[j]
-				childNode, found := sm.dict[PrefixTreeNode{p.NodeID, p.Offset, ch}]
-				if !found {
-					continue
-				}
-				p.NodeID = childNode.ChildID
-				p.IsFinal = childNode.IsFinal
-				p.Offset++
-				sm.pointers[newIndex] = p
-				newIndex++
-			}
-			sm.pointers = sm.pointers[:newIndex]
-
-			for _, pointer := range sm.pointers {
-				if pointer.IsFinal {
-					s := 1 + i - pointer.Offset
-					source := sm.path[s]
-					edge := Edge{
-						S:         s,
-						WordCount: source.WordCount + 1,
-						UnkCount:  source.UnkCount,
-					}
-
-					if !bestEdge.Valid ||
-						edge.UnkCount < bestEdge.UnkCount ||
-						(edge.UnkCount == bestEdge.UnkCount &&
-							edge.WordCount <= bestEdge.WordCount) {
-						bestEdge.Set(edge)
-					}
-				}
+			// only start a new pointer when ch can begin a dictionary word
+			if sm.starts[ch] {
+				sm.pointers = append(sm.pointers, DictBuilderPointer{})
 			}
+			sm.advancePointers(ch)
+			sm.considerPointers(&bestEdge, line, i)
 		}
 
 		if !bestEdge.Valid {
+			// the repetition mark ends the unknown word before it and is
+			// an unknown word of its own, like a space run
+			isRepeat := ch == 'ๆ'
+			if isRepeat {
+				word.Left = i
+			}
 			source := sm.path[word.Left]
+			unk := sm.unkCost(line, word.Left, i+1)
+			// unknown characters since the last known word are grouped
+			// into one unknown word when GroupUnknown is set
 			bestEdge.Set(Edge{
 				S:         word.Left,
 				WordCount: source.WordCount + 1,
-				UnkCount:  source.UnkCount + 1,
+				UnkCount:  source.UnkCount + unk,
 			})
+			bestEdge.Unknown = true
+			if isRepeat || !sm.GroupUnknown || (sm.UnknownChunk > 0 && i+1-word.Left >= sm.UnknownChunk) {
+				word.Left = i + 1
+			}
 		} else {
 			word.Left = i + 1
 		}
 		sm.path[i+1] = bestEdge.Edge
+		sm.ties[i+1] = bestEdge.Tie
+		sm.nodes[i+1] = bestEdge.NodeID
+		sm.oov[i+1] = bestEdge.Unknown
+		sm.updateLengths(i + 1)
+	}
+
+	if sm.fallback != nil {
+		sm.segmentFallback(line)
+	}
+}
+
+// EdgeComparator chooses between two edges ending at the same position
+type EdgeComparator interface {
+	// Better reports whether a should be chosen over b
+	Better(a, b Edge) bool
+}
+
+// DefaultEdgeComparator prefers the fewest unknown words, then the fewest
+// words, like BuildPath without a Comparator and LengthBonus
+type DefaultEdgeComparator struct{}
+
+func (DefaultEdgeComparator) Better(a, b Edge) bool {
+	return a.UnkCount < b.UnkCount ||
+		(a.UnkCount == b.UnkCount && a.WordCount < b.WordCount)
+}
+
+// considerPointers considers the dictionary words ending at line[i]
+func (sm *Segmenter) considerPointers(bestEdge *edgeCandidate, line []rune, i int) {
+	for _, pointer := range sm.pointers {
+		if pointer.IsFinal {
+			s := 1 + i - pointer.Offset
+			source := sm.path[s]
+			edge := Edge{
+				S:         s,
+				WordCount: source.WordCount + 1,
+				UnkCount:  source.UnkCount,
+			}
+
+			n := i + 1 - s
+			bestEdge.Consider(edge, sm.cost(edge, sm.lengths[s]+n*n), pointer.NodeID, sm.rank(line, s, i+1))
+		}
+	}
+}
+
+// betterThanRun reports whether the dictionary word edge is kept instead
+// of the run edge ending at the same position
+func betterThanRun(edge, run Edge) bool {
+	return edge.UnkCount < run.UnkCount ||
+		(edge.UnkCount == run.UnkCount && edge.WordCount <= run.WordCount)
+}
+
+// edgeCandidate is the best edge found so far ending at a position
+type edgeCandidate struct {
+	NullEdge
+	Cost       float64
+	NodeID     int
+	Rank       int
+	Tie        bool
+	Unknown    bool
+	comparator EdgeComparator
+}
+
+// Consider replaces the candidate with edge when edge has fewer unknown
+// words, or as many and a lower cost, or when the comparator finds edge
+// better. On equal cost the word of lower rank, then the longer word wins.
+func (b *edgeCandidate) Consider(edge Edge, cost float64, nodeID, rank int) {
+	if b.comparator != nil {
+		switch {
+		case !b.Valid || b.comparator.Better(edge, b.Edge):
+			b.take(edge, nodeID, rank)
+			b.Tie = false
+		case !b.comparator.Better(b.Edge, edge):
+			if rank < b.Rank || (rank == b.Rank && edge.S < b.S) {
+				b.take(edge, nodeID, rank)
+			}
+			b.Tie = true
+		}
+		return
+	}
+
+	switch {
+	case !b.Valid ||
+		edge.UnkCount < b.UnkCount ||
+		(edge.UnkCount == b.UnkCount && cost < b.Cost):
+		b.take(edge, nodeID, rank)
+		b.Cost = cost
+		b.Tie = false
+	case edge.UnkCount == b.UnkCount && cost == b.Cost:
+		// on equal cost prefer the preferred, then the longer word
+		if rank < b.Rank || (rank == b.Rank && edge.S < b.S) {
+			b.take(edge, nodeID, rank)
+		}
+		b.Tie = true
+	}
+}
+
+// take makes edge the candidate
+func (b *edgeCandidate) take(edge Edge, nodeID, rank int) {
+	b.Set(edge)
+	b.NodeID = nodeID
+	b.Rank = rank
+	b.Unknown = nodeID == UnknownNodeID
+}
+
+// SetWordOrder ranks the dictionary words by their index in words, when
+// edges cost the same the word found first in words is chosen. Words not
+// in words rank after all of them. The order is kept when the dictionary
+// is replaced, nil words removes it. Only the first dictionary of a
+// DictChain is ranked.
+func (sm *Segmenter) SetWordOrder(words []string) {
+	if words == nil {
+		sm.ranks = nil
+		return
+	}
+
+	// ranks are keyed by the word, minimized dictionaries share the nodes
+	// words end at
+	sm.ranks = make(map[string]int, len(words))
+	for rank, word := range words {
+		if _, ranked := sm.ranks[word]; !ranked {
+			sm.ranks[word] = rank
+		}
+	}
+}
+
+// rank is the SetWordOrder rank of the word line[s:e]
+func (sm *Segmenter) rank(line []rune, s, e int) int {
+	if sm.ranks == nil {
+		return 0
+	}
+	if rank, ok := sm.ranks[string(line[s:e])]; ok {
+		return rank
+	}
+	return math.MaxInt
+}
+
+// cost is the word count of edge less the LengthBonus of the squared
+// dictionary word lengths on its path
+func (sm *Segmenter) cost(edge Edge, lengths int) float64 {
+	return float64(edge.WordCount) - sm.LengthBonus*float64(lengths)
+}
+
+// unkCost is the UnkCount increment of the unknown word line[s:e]
+func (sm *Segmenter) unkCost(line []rune, s, e int) int {
+	if sm.UnkPenalty == nil {
+		return 1
+	}
+
+	unk := 0
+	for _, c := range line[s:e] {
+		unk += sm.UnkPenalty.Of(c)
+	}
+	return unk
+}
+
+// updateLengths sums the squared lengths of the dictionary words on the
+// path ending at e
+func (sm *Segmenter) updateLengths(e int) {
+	s := sm.path[e].S
+	sm.lengths[e] = sm.lengths[s]
+	if sm.nodes[e] != UnknownNodeID && sm.path[e].UnkCount == sm.path[s].UnkCount {
+		sm.lengths[e] += (e - s) * (e - s)
+	}
+}
+
+// advancePointers moves every pointer to its child for ch and drops the
+// pointers without one
+func (sm *Segmenter) advancePointers(ch rune) {
+	newIndex := 0
+	for j, _ := range sm.pointers {
+		p := sm.pointers[j]
+		childNode, found := sm.dict[PrefixTreeNode{p.NodeID, p.Offset, ch}]
+		if !found {
+			continue
+		}
+		p.NodeID = childNode.ChildID
+		p.IsFinal = childNode.IsFinal
+		p.Offset++
+		sm.pointers[newIndex] = p
+		newIndex++
+	}
+	sm.pointers = sm.pointers[:newIndex]
+}
+
+// RuneClassifier reports the WordType of ch, ok is false when it does not
+// classify ch
+type RuneClassifier func(ch rune) (wordType WordType, ok bool)
+
+// runType returns the type of run line[i] belongs to, Text when it is
+// matched against the dictionary
+func (sm *Segmenter) runType(line []rune, i int, current WordType) WordType {
+	ch := line[i]
+	if len(sm.HardBoundaries) > 0 && unicode.IsOneOf(sm.HardBoundaries, ch) {
+		return Foreign
+	}
+	for _, classify := range sm.Classifiers {
+		if wordType, ok := classify(ch); ok {
+			return wordType
+		}
+	}
+	switch {
+	case (current == Latin || current == Text) && i > 0 && i+1 < len(line) &&
+		strings.ContainsRune(sm.Joiners, ch) && sm.runType(line, i+1, current) == current:
+		return current
+	case IsLatin(ch):
+		return Latin
+	case sm.isSpace(ch):
+		return Space
+	case IsSymbol(ch):
+		return Symbol
+	case IsDigit(ch):
+		return Number
+	case (ch == '.' || ch == ',') && current == Number &&
+		i+1 < len(line) && IsDigit(line[i+1]):
+		return Number
+	}
+	return Text
+}
+
+// isSpace reports whether ch belongs to a Space run, with UnicodeSpaces set
+// zero-width and narrow spaces do too
+func (sm *Segmenter) isSpace(ch rune) bool {
+	return IsSpace(ch) || (sm.UnicodeSpaces && IsUnicodeSpace(ch))
+}
+
+// appendRunAt ends the current Space or Latin run at i
+func (sm *Segmenter) appendRunAt(word *Word, line []rune, i int) {
+	if word.Type == Latin && sm.FoldLatinMatch && sm.matchLatin(line, word.Start, i) {
+		word.Type = Unknow
+		word.Left = i
+		return
+	}
+	unk := sm.runCost(word, line, i)
+	// a dictionary word spanning the run ends here
+	if sm.MixedWords && sm.nodes[i] != UnknownNodeID {
+		if source := sm.path[word.Start]; betterThanRun(sm.path[i], Edge{
+			S:         word.Start,
+			WordCount: source.WordCount + 1,
+			UnkCount:  source.UnkCount + unk,
+		}) {
+			word.Type = Unknow
+			word.Left = i
+			return
+		}
+	}
+	word.AppendEdgeAt(i)
+	sm.path[i].UnkCount += unk
+	sm.nodes[i] = UnknownNodeID
+	sm.oov[i] = false
+	sm.updateLengths(i)
+}
+
+// runEdge ends the current run at the end of the line
+func (sm *Segmenter) runEdge(word *Word, line []rune, end int) Edge {
+	unk := sm.runCost(word, line, end)
+	run := word.GetEdge()
+	run.UnkCount += unk
+	return run
+}
+
+// runCost is the UnkCount increment of the run line[word.Start:end]. With
+// UnkPenalty set a Latin run kept whole is an unknown word and costs the
+// same as the unknown chunks matchLatin charges; the other runs are free.
+func (sm *Segmenter) runCost(word *Word, line []rune, end int) int {
+	if sm.UnkPenalty == nil || word.Type != Latin {
+		return 0
+	}
+	return sm.unkCost(line, word.Start, end)
+}
+
+// matchLatin looks up the lower cased Latin run line[start:end] in the
+// dictionary. When the run is covered by dictionary words the edges of the
+// fewest words are written to the path. With UnkPenalty set the run may also
+// be split into dictionary words and unknown chunks when that costs less
+// than keeping the whole run.
+func (sm *Segmenter) matchLatin(line []rune, start, end int) bool {
+	n := end - start
+	sm.lower = sm.lower[:0]
+	for _, ch := range line[start:end] {
+		sm.lower = append(sm.lower, unicode.ToLower(ch))
+	}
+
+	// unk[j] and words[j] are the cost of the best split of lower[:j],
+	// prev[j] its last start and ids[j] the node its last word ended at
+	unk := make([]int, n+1)
+	words := make([]int, n+1)
+	prev := make([]int, n+1)
+	ids := make([]int, n+1)
+	for j := 1; j <= n; j++ {
+		words[j] = -1
+	}
+
+	better := func(j, u, w int) bool {
+		return words[j] < 0 || u < unk[j] || (u == unk[j] && w < words[j])
+	}
+
+	for s := 0; s < n; s++ {
+		if words[s] < 0 {
+			continue
+		}
+		nodeID := 0
+		for j := s; j < n; j++ {
+			child, found := sm.dict[PrefixTreeNode{nodeID, j - s, sm.lower[j]}]
+			if !found {
+				break
+			}
+			if child.IsFinal && better(j+1, unk[s], words[s]+1) {
+				unk[j+1] = unk[s]
+				words[j+1] = words[s] + 1
+				prev[j+1] = s
+				ids[j+1] = child.ChildID
+			}
+			nodeID = child.ChildID
+		}
+
+		if sm.UnkPenalty != nil {
+			cost := 0
+			for j := s + 1; j <= n; j++ {
+				cost += sm.UnkPenalty.Of(line[start+j-1])
+				if better(j, unk[s]+cost, words[s]+1) {
+					unk[j] = unk[s] + cost
+					words[j] = words[s] + 1
+					prev[j] = s
+					ids[j] = UnknownNodeID
+				}
+			}
+		}
+	}
+
+	// keep the whole run when no split is better
+	if words[n] < 0 || (prev[n] == 0 && ids[n] == UnknownNodeID) {
+		return false
+	}
+
+	var bounds []int
+	for j := n; j > 0; j = prev[j] {
+		bounds = append(bounds, j)
+	}
+	s, b := start, 0
+	for k := len(bounds) - 1; k >= 0; k-- {
+		e := start + bounds[k]
+		source := sm.path[s]
+		sm.path[e] = Edge{
+			S:         s,
+			WordCount: source.WordCount + 1,
+			UnkCount:  source.UnkCount + unk[bounds[k]] - unk[b],
+		}
+		sm.nodes[e] = ids[bounds[k]]
+		sm.oov[e] = false
+		sm.updateLengths(e)
+		s, b = e, bounds[k]
+	}
+
+	return true
+}
+
+// ScriptPenalty is the UnkCount cost of an unknown character by its script
+type ScriptPenalty struct {
+	Latin int
+	Thai  int
+	Other int
+}
+
+// Of returns the penalty of the unknown character ch
+func (p *ScriptPenalty) Of(ch rune) int {
+	switch {
+	case IsLatin(ch):
+		return p.Latin
+	case unicode.Is(unicode.Thai, ch):
+		return p.Thai
+	default:
+		return p.Other
 	}
 }
 
@@ -392,6 +1931,9 @@ const (
 	Space
 	Latin
 	Text
+	Symbol
+	Number
+	Foreign
 )
 
 type Word struct {
@@ -1,20 +1,29 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"reflect"
+	"runtime"
+	"sort"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
+	"unicode"
 )
 
-func lookup(t PrefixTree, i int, j int, ch rune) (PrefixTreePointer, bool) {
-	p, f := t[PrefixTreeNode{i, j, ch}]
-	return p, f
-}
-
 func TestOneCharPrefixTree(t *testing.T) {
 	words := []string{"A"}
 	prefixTree := MakePrefixTree(words)
 	expect := PrefixTreePointer{0, true}
-	testLookup(t, expect, "Expect to find 0, 0, A")(lookup(prefixTree, 0, 0, 'A'))
+	testLookup(t, expect, "Expect to find 0, 0, A")(prefixTree.Lookup(0, 0, 'A'))
 }
 
 func TestOneWordPrefixTree(t *testing.T) {
@@ -24,10 +33,10 @@ func TestOneWordPrefixTree(t *testing.T) {
 	var expect PrefixTreePointer
 
 	expect = PrefixTreePointer{0, false}
-	testLookup(t, expect, "Expect to find 0, 0, A")(lookup(prefixTree, 0, 0, 'A'))
+	testLookup(t, expect, "Expect to find 0, 0, A")(prefixTree.Lookup(0, 0, 'A'))
 
 	expect = PrefixTreePointer{0, true}
-	testLookup(t, expect, "Expect to find 0, 1, B")(lookup(prefixTree, 0, 1, 'B'))
+	testLookup(t, expect, "Expect to find 0, 1, B")(prefixTree.Lookup(0, 1, 'B'))
 }
 
 func TestTwoWordsPrefixTree(t *testing.T) {
@@ -37,16 +46,16 @@ func TestTwoWordsPrefixTree(t *testing.T) {
 	var expect PrefixTreePointer
 
 	expect = PrefixTreePointer{0, false}
-	testLookup(t, expect, "Expect to find 0, 0, A")(lookup(prefixTree, 0, 0, 'A'))
+	testLookup(t, expect, "Expect to find 0, 0, A")(prefixTree.Lookup(0, 0, 'A'))
 
 	expect = PrefixTreePointer{0, true}
-	testLookup(t, expect, "Expect to find 0, 1, B")(lookup(prefixTree, 0, 1, 'B'))
+	testLookup(t, expect, "Expect to find 0, 1, B")(prefixTree.Lookup(0, 1, 'B'))
 
 	expect = PrefixTreePointer{1, true}
-	testLookup(t, expect, "Expect to find 0, 1, C")(lookup(prefixTree, 0, 1, 'C'))
+	testLookup(t, expect, "Expect to find 0, 1, C")(prefixTree.Lookup(0, 1, 'C'))
 
 	expect = PrefixTreePointer{2, true}
-	testLookup(t, expect, "Expect to find 0, 0, D")(lookup(prefixTree, 0, 0, 'D'))
+	testLookup(t, expect, "Expect to find 0, 0, D")(prefixTree.Lookup(0, 0, 'D'))
 }
 
 func TestKaPrefixTree(t *testing.T) {
@@ -55,10 +64,10 @@ func TestKaPrefixTree(t *testing.T) {
 	var expect PrefixTreePointer
 
 	expect = PrefixTreePointer{0, false}
-	testLookup(t, expect, "Expect to find 0, 0, ก")(lookup(prefixTree, 0, 0, 'ก'))
+	testLookup(t, expect, "Expect to find 0, 0, ก")(prefixTree.Lookup(0, 0, 'ก'))
 
 	expect = PrefixTreePointer{0, true}
-	testLookup(t, expect, "Expect to find 0, 1, า")(lookup(prefixTree, 0, 1, 'า'))
+	testLookup(t, expect, "Expect to find 0, 1, า")(prefixTree.Lookup(0, 1, 'า'))
 }
 
 func TestViaDict(t *testing.T) {
@@ -66,27 +75,27 @@ func TestViaDict(t *testing.T) {
 	var child PrefixTreePointer
 	var found bool
 
-	child, found = lookup(dict, 0, 0, 'ม')
+	child, found = dict.Lookup(0, 0, 'ม')
 	if !found {
 		t.Errorf("Expect to find ม")
 	}
 
-	child, found = lookup(dict, child.ChildID, 1, 'า')
+	child, found = dict.Lookup(child.ChildID, 1, 'า')
 	if !found {
 		t.Errorf("Expect to find า")
 	}
 
-	child, found = lookup(dict, child.ChildID, 2, 'ต')
+	child, found = dict.Lookup(child.ChildID, 2, 'ต')
 	if !found {
 		t.Errorf("Expect to find ต")
 	}
 
-	child, found = lookup(dict, child.ChildID, 3, 'ร')
+	child, found = dict.Lookup(child.ChildID, 3, 'ร')
 	if !found {
 		t.Errorf("Expect to find ร")
 	}
 
-	child, found = lookup(dict, child.ChildID, 4, 'า')
+	child, found = dict.Lookup(child.ChildID, 4, 'า')
 	if !found {
 		t.Errorf("Expect to find last า")
 	}
@@ -97,13 +106,103 @@ func TestViaDict(t *testing.T) {
 
 }
 
+func TestPrefixTreeCounts(t *testing.T) {
+	dict := MakePrefixTree([]string{"กิน", "กินข้าว", "ข้าว", "มา", "มาก"})
+
+	if n := dict.NodeCount(); n != 14 {
+		t.Errorf("Expect 14 nodes got %d", n)
+	}
+	if n := dict.Len(); n != 5 {
+		t.Errorf("Expect 5 words got %d", n)
+	}
+
+	minimized := dict.Minimize()
+	if n := minimized.Len(); n != 5 {
+		t.Errorf("Expect 5 words after Minimize got %d", n)
+	}
+	if n := minimized.NodeCount(); n > dict.NodeCount() {
+		t.Errorf("Expect at most %d nodes after Minimize got %d", dict.NodeCount(), n)
+	}
+}
+
+func TestDefaultDictCached(t *testing.T) {
+	if err := PreloadDefaultDict(); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := DefaultDict()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := DefaultDict()
+	if reflect.ValueOf(a).Pointer() != reflect.ValueOf(b).Pointer() {
+		t.Error("Expect DefaultDict to return the cached prefix tree")
+	}
+	if a.Len() == 0 {
+		t.Error("Expect the default dictionary to have words")
+	}
+}
+
+func TestLoadDictFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"dict/words.txt": &fstest.MapFile{Data: []byte("ไป\nกิน\nข้าว\n")},
+	}
+
+	dict, err := LoadDictFS(fsys, "dict/words.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := []string{"กิน", "ข้าว", "ไป"}; !reflect.DeepEqual(expect, dict.Words()) {
+		t.Errorf("Expect %v got %v", expect, dict.Words())
+	}
+
+	if _, err := LoadDictFS(fsys, "missing.txt"); err == nil {
+		t.Error("Expect error for a missing file")
+	}
+}
+
+func ExamplePrefixTree_Lookup() {
+	dict, _ := LoadDefaultDict()
+
+	nodeID := 0
+	for offset, ch := range []rune("มาตรา") {
+		child, found := dict.Lookup(nodeID, offset, ch)
+		if !found {
+			fmt.Println("not found")
+			return
+		}
+		fmt.Printf("%c final=%v\n", ch, child.IsFinal)
+		nodeID = child.ChildID
+	}
+	// Output:
+	// ม final=false
+	// า final=true
+	// ต final=false
+	// ร final=true
+	// า final=true
+}
+
+func TestIsWordStart(t *testing.T) {
+	dict, _ := LoadDefaultDict()
+
+	if !dict.IsWordStart('ก') {
+		t.Errorf("Expect ก to begin a word")
+	}
+	if dict.IsWordStart('a') {
+		t.Errorf("Expect a not to begin a word")
+	}
+	if dict.IsWordStart('ะ') {
+		t.Errorf("Expect ะ not to begin a word")
+	}
+}
+
 func TestViaDictNotFinal(t *testing.T) {
 	dict, _ := LoadDefaultDict()
 	var child PrefixTreePointer
 
-	child, _ = lookup(dict, 0, 0, 'ต')
+	child, _ = dict.Lookup(0, 0, 'ต')
 
-	child, _ = lookup(dict, child.ChildID, 1, 'ร')
+	child, _ = dict.Lookup(child.ChildID, 1, 'ร')
 
 	if child.IsFinal {
 		t.Errorf("Expect last ร not to be final")
@@ -111,14 +210,1447 @@ func TestViaDictNotFinal(t *testing.T) {
 
 }
 
+func TestSegmentTSVColumn(t *testing.T) {
+	w := &SegmenterWorker{
+		dict:       MakePrefixTree([]string{"ไป", "กิน", "ข้าว"}),
+		Columns:    []int{1},
+		FieldDelim: "\t",
+	}
+
+	var out bytes.Buffer
+	if err := w.RunIO(strings.NewReader("ไปกิน\tไปกินข้าว\nกินข้าว\tกินข้าว\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := "ไปกิน\tไป|กิน|ข้าว\nกินข้าว\tกิน|ข้าว\n"
+	if out.String() != expect {
+		t.Errorf("Expect %q got %q", expect, out.String())
+	}
+}
+
 func testLookup(t *testing.T, expect PrefixTreePointer, msg string) func(PrefixTreePointer, bool) {
 	return func(child PrefixTreePointer, found bool) {
 		if !found {
-			t.Errorf(msg)
+			t.Error(msg)
 		}
 
 		if !reflect.DeepEqual(expect, child) {
-			t.Errorf("Expect %q got %q", expect, child)
+			t.Errorf("Expect %v got %v", expect, child)
+		}
+	}
+}
+
+const benchParagraph = "ประเทศไทยมีประชากรประมาณหกสิบหกล้านคน กรุงเทพมหานครเป็นเมืองหลวงและเป็นศูนย์กลางทางเศรษฐกิจ " +
+	"การศึกษาภาษาไทยช่วยให้เข้าใจวัฒนธรรมและประวัติศาสตร์ของประเทศได้ดีขึ้น"
+
+// sortedTrieEntry is one edge of sortedTrie
+type sortedTrieEntry struct {
+	PrefixTreeNode
+	PrefixTreePointer
+}
+
+// sortedTrie is a sorted-slice alternative to the map based PrefixTree
+type sortedTrie []sortedTrieEntry
+
+func nodeLess(a, b PrefixTreeNode) bool {
+	if a.NodeID != b.NodeID {
+		return a.NodeID < b.NodeID
+	}
+	if a.Offset != b.Offset {
+		return a.Offset < b.Offset
+	}
+	return a.Ch < b.Ch
+}
+
+func makeSortedTrie(t PrefixTree) sortedTrie {
+	st := make(sortedTrie, 0, len(t))
+	for node, pointer := range t {
+		st = append(st, sortedTrieEntry{node, pointer})
+	}
+	sort.Slice(st, func(i, j int) bool {
+		return nodeLess(st[i].PrefixTreeNode, st[j].PrefixTreeNode)
+	})
+	return st
+}
+
+func (st sortedTrie) lookup(node PrefixTreeNode) (PrefixTreePointer, bool) {
+	i := sort.Search(len(st), func(i int) bool {
+		return !nodeLess(st[i].PrefixTreeNode, node)
+	})
+	if i < len(st) && st[i].PrefixTreeNode == node {
+		return st[i].PrefixTreePointer, true
+	}
+	return PrefixTreePointer{}, false
+}
+
+func (st sortedTrie) LongestMatchAt(runes []rune, i int) int {
+	longest := 0
+	nodeID := 0
+	for j := i; j < len(runes); j++ {
+		child, found := st.lookup(PrefixTreeNode{nodeID, j - i, runes[j]})
+		if !found {
+			break
+		}
+		if child.IsFinal {
+			longest = j - i + 1
+		}
+		nodeID = child.ChildID
+	}
+
+	return longest
+}
+
+func TestLongestMatchAt(t *testing.T) {
+	dict := MakePrefixTree([]string{"ไป", "กิน", "กินข้าว", "ข้าว"})
+	runes := []rune("ไปกินข้าว")
+
+	expects := []int{2, 0, 7, 0, 0, 4, 0, 0, 0}
+	for i, expect := range expects {
+		if got := dict.LongestMatchAt(runes, i); got != expect {
+			t.Errorf("Expect match length %d at %d got %d", expect, i, got)
+		}
+	}
+}
+
+func TestSortedTrieAgreesWithPrefixTree(t *testing.T) {
+	dict, err := LoadDefaultDict()
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := makeSortedTrie(dict)
+
+	runes := []rune(benchParagraph)
+	for i := range runes {
+		if a, b := dict.LongestMatchAt(runes, i), st.LongestMatchAt(runes, i); a != b {
+			t.Errorf("Expect both tries to match %d runes at %d, map %d sorted slice %d", a, i, a, b)
+		}
+	}
+}
+
+func BenchmarkLongestMatchAtMap(b *testing.B) {
+	dict, err := LoadDefaultDict()
+	if err != nil {
+		b.Fatal(err)
+	}
+	runes := []rune(benchParagraph)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for i := range runes {
+			dict.LongestMatchAt(runes, i)
+		}
+	}
+}
+
+func BenchmarkLongestMatchAtSortedSlice(b *testing.B) {
+	dict, err := LoadDefaultDict()
+	if err != nil {
+		b.Fatal(err)
+	}
+	st := makeSortedTrie(dict)
+	runes := []rune(benchParagraph)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for i := range runes {
+			st.LongestMatchAt(runes, i)
+		}
+	}
+}
+
+func TestSegmentSkipsNonStartingRunes(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป", "กิน", "ข้าว"}))
+
+	expect := []string{"ไป", "ะ", "กิน", "ข้าว"}
+	if got := sm.Segment([]rune("ไปะกินข้าว")); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+}
+
+func BenchmarkBuildPathNonStartingRunes(b *testing.B) {
+	dict, err := LoadDefaultDict()
+	if err != nil {
+		b.Fatal(err)
+	}
+	sm := NewSegmenter(dict)
+	runes := []rune(strings.Repeat("ะาิีึืุู่้๊๋์ๆ๑๒๓", 20) + benchParagraph)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		sm.BuildPath(runes)
+	}
+}
+
+func TestSegmentAppend(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป", "กิน", "ข้าว"}))
+
+	dst := []string{"old", "tokens", "left", "here", "too"}
+	got := sm.SegmentAppend(dst, []rune("ไปกินข้าว"))
+	expect := []string{"ไป", "กิน", "ข้าว"}
+	if !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+	if &got[0] != &dst[0] {
+		t.Error("Expect the backing array of dst to be reused")
+	}
+
+	for _, text := range []string{"ไป กิน", "ข้าวไปกินไปกินข้าว", "ไป"} {
+		expect := sm.Segment([]rune(text))
+		if got = sm.SegmentAppend(got, []rune(text)); !reflect.DeepEqual(expect, got) {
+			t.Errorf("Expect %v got %v", expect, got)
+		}
+	}
+}
+
+func BenchmarkSegment(b *testing.B) {
+	dict, err := LoadDefaultDict()
+	if err != nil {
+		b.Fatal(err)
+	}
+	sm := NewSegmenter(dict)
+	runes := []rune(benchParagraph)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		sm.Segment(runes)
+	}
+}
+
+func BenchmarkSegmentAppend(b *testing.B) {
+	dict, err := LoadDefaultDict()
+	if err != nil {
+		b.Fatal(err)
+	}
+	sm := NewSegmenter(dict)
+	runes := []rune(benchParagraph)
+
+	var tokens []string
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		tokens = sm.SegmentAppend(tokens, runes)
+	}
+}
+
+func TestSegmentStopwords(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ฉัน", "จะ", "ไป", "กิน", "ข้าว"}))
+	sm.Stopwords = map[string]struct{}{"จะ": {}, " ": {}}
+
+	expect := []string{"ฉัน", "ไป", "กิน", "ข้าว"}
+	if got := sm.Segment([]rune("ฉันจะไป กินข้าว")); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+}
+
+func TestSegmentAmbiguous(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ตา", "ตาก", "กลม", "ลม", "ไป"}))
+
+	tokens, ambiguous := sm.SegmentAmbiguous([]rune("ไปตากลม"))
+	if len(tokens) != 3 || len(ambiguous) != 3 {
+		t.Fatalf("Expect 3 tokens got %v", tokens)
+	}
+	if ambiguous[0] || ambiguous[1] {
+		t.Errorf("Expect only the last token to be ambiguous got %v", ambiguous)
+	}
+	if !ambiguous[2] {
+		t.Errorf("Expect ตากลม to be ambiguous got %v %v", tokens, ambiguous)
+	}
+
+	_, ambiguous = sm.SegmentAmbiguous([]rune("ไปตา"))
+	for i, a := range ambiguous {
+		if a {
+			t.Errorf("Expect token %d not to be ambiguous", i)
+		}
+	}
+}
+
+func TestSegmentFoldLatinMatch(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"apple", "pie", "กิน"}))
+
+	expect := []string{"กิน", "ApplePie"}
+	if got := sm.Segment([]rune("กินApplePie")); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+
+	sm.FoldLatinMatch = true
+
+	expect = []string{"กิน", "Apple", "Pie"}
+	if got := sm.Segment([]rune("กินApplePie")); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+
+	expect = []string{"Apple", " ", "กิน"}
+	if got := sm.Segment([]rune("Apple กิน")); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+
+	expect = []string{"Applex", "กิน"}
+	if got := sm.Segment([]rune("Applexกิน")); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+}
+
+func TestSegmentSymbol(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป", "กิน", "ข้าว"}))
+	text := []rune("ไปกินข้าว😀😋ไป")
+
+	expect := []string{"ไป", "กิน", "ข้าว", "😀😋", "ไป"}
+	if got := sm.Segment(text); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+
+	if unk := sm.path[len(text)].UnkCount; unk != 0 {
+		t.Errorf("Expect symbols not to be unknown got UnkCount %d", unk)
+	}
+}
+
+func TestLoadDictFieldsReader(t *testing.T) {
+	dict, err := LoadDictFieldsReader(strings.NewReader("ไป กิน\tข้าว\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, word := range []string{"ไป", "กิน", "ข้าว"} {
+		runes := []rune(word)
+		if got := dict.LongestMatchAt(runes, 0); got != len(runes) {
+			t.Errorf("Expect to find %s got match length %d", word, got)
+		}
+	}
+
+	if got := dict.LongestMatchAt([]rune("ไป กิน"), 0); got != 2 {
+		t.Errorf("Expect words to be split on whitespace got match length %d", got)
+	}
+
+	// a byte order mark is trimmed and gzip input is decompressed
+	var b bytes.Buffer
+	zw := gzip.NewWriter(&b)
+	zw.Write([]byte("\ufeffไป\tกิน\n"))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []io.Reader{strings.NewReader("\ufeffไป\tกิน\n"), &b} {
+		dict, err := LoadDictFieldsReader(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := dict.LongestMatchAt([]rune("ไป"), 0); got != 2 {
+			t.Errorf("Expect to find the first word got match length %d", got)
+		}
+	}
+}
+
+// endlessDict reads word lines forever and cancels after reading cancelAt
+// bytes
+type endlessDict struct {
+	read     int
+	cancelAt int
+	cancel   context.CancelFunc
+}
+
+func (r *endlessDict) Read(p []byte) (int, error) {
+	n := copy(p, strings.Repeat("กิน\n", len(p)/len("กิน\n")))
+	r.read += n
+	if r.read >= r.cancelAt {
+		r.cancel()
+	}
+	return n, nil
+}
+
+func TestLoadDictContext(t *testing.T) {
+	dict, err := LoadDictContext(context.Background(), strings.NewReader("\ufeffไป\n\nกิน\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, word := range []string{"ไป", "กิน"} {
+		runes := []rune(word)
+		if got := dict.LongestMatchAt(runes, 0); got != len(runes) {
+			t.Errorf("Expect to find %s got match length %d", word, got)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, err = LoadDictContext(ctx, &endlessDict{cancelAt: 1 << 20, cancel: cancel})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expect %v got %v", context.Canceled, err)
+	}
+}
+
+func TestLoadDictFreqReader(t *testing.T) {
+	dict, freq, err := LoadDictFreqReader(strings.NewReader("ไป\t120\nกิน\t45\nข้าว\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, word := range []string{"ไป", "กิน", "ข้าว"} {
+		runes := []rune(word)
+		if got := dict.LongestMatchAt(runes, 0); got != len(runes) {
+			t.Errorf("Expect to find %s got match length %d", word, got)
+		}
+	}
+
+	expect := map[string]int{"ไป": 120, "กิน": 45, "ข้าว": 1}
+	if !reflect.DeepEqual(expect, freq) {
+		t.Errorf("Expect %v got %v", expect, freq)
+	}
+
+	if _, _, err := LoadDictFreqReader(strings.NewReader("ไป\tmany\n")); err == nil {
+		t.Error("Expect error for an invalid frequency")
+	}
+}
+
+func TestSegmentTiePrefersLongerWord(t *testing.T) {
+	words := []string{"ไป", "ตา", "ตาก", "กลม", "ลม"}
+	expect := []string{"ไป", "ตา", "กลม"}
+
+	for _, order := range [][]int{{0, 1, 2, 3, 4}, {4, 3, 2, 1, 0}, {2, 4, 0, 3, 1}} {
+		b := NewDictBuilder()
+		for _, i := range order {
+			b.AddWord(words[i])
+		}
+
+		sm := NewSegmenter(b.Build())
+		if got := sm.Segment([]rune("ไปตากลม")); !reflect.DeepEqual(expect, got) {
+			t.Errorf("Expect %v got %v with word order %v", expect, got, order)
+		}
+	}
+}
+
+func TestSegmentNodeIDs(t *testing.T) {
+	dict := MakePrefixTree([]string{"ไป", "กิน", "ข้าว", "apple"})
+	sm := NewSegmenter(dict)
+	sm.FoldLatinMatch = true
+
+	tokens, nodeIDs := sm.SegmentNodeIDs([]rune("ไปกินApple ข้าวปลา"))
+	_, latinNodeIDs := sm.SegmentNodeIDs([]rune("Apple"))
+	if latinNodeIDs[0] != nodeIDs[2] {
+		t.Errorf("Expect Apple at end of line to end at node %d got %d", nodeIDs[2], latinNodeIDs[0])
+	}
+
+	expect := []string{"ไป", "กิน", "Apple", " ", "ข้าว", "ปลา"}
+	if !reflect.DeepEqual(expect, tokens) {
+		t.Fatalf("Expect %v got %v", expect, tokens)
+	}
+
+	for i, token := range tokens {
+		if i == 3 || i == 5 {
+			if nodeIDs[i] != UnknownNodeID {
+				t.Errorf("Expect %q to be unknown got node %d", token, nodeIDs[i])
+			}
+			continue
+		}
+
+		var child PrefixTreePointer
+		for offset, ch := range []rune(strings.ToLower(token)) {
+			child, _ = dict.Lookup(child.ChildID, offset, ch)
+		}
+		if !child.IsFinal || child.ChildID != nodeIDs[i] {
+			t.Errorf("Expect %q to end at node %d got %d", token, child.ChildID, nodeIDs[i])
+		}
+	}
+}
+
+func TestSegmentUnkPenalty(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"กิน", "apple"}))
+	sm.FoldLatinMatch = true
+	text := []rune("กินApplexyz")
+
+	expect := []string{"กิน", "Applexyz"}
+	if got := sm.Segment(text); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+
+	sm.UnkPenalty = &ScriptPenalty{Latin: 1, Thai: 1, Other: 1}
+
+	expect = []string{"กิน", "Apple", "xyz"}
+	if got := sm.Segment(text); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+	if unk := sm.path[len(text)].UnkCount; unk != 3 {
+		t.Errorf("Expect UnkCount 3 got %d", unk)
+	}
+
+	sm.UnkPenalty.Latin = 0
+
+	expect = []string{"กิน", "Applexyz"}
+	if got := sm.Segment(text); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+
+	// a Latin run kept whole is charged like a split one, with or without
+	// FoldLatinMatch and wherever the run ends
+	sm.UnkPenalty.Latin = 2
+	for _, fold := range []bool{true, false} {
+		sm.FoldLatinMatch = fold
+		for _, line := range []string{"กินxyz", "xyzกิน", "กิน xyz กิน"} {
+			text := []rune(line)
+			sm.Segment(text)
+			if unk := sm.path[len(text)].UnkCount; unk != 6 {
+				t.Errorf("FoldLatinMatch %v %q: Expect UnkCount 6 got %d", fold, line, unk)
+			}
+		}
+	}
+}
+
+func TestSegmentHardBoundaries(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป", "กิน", "ข้าว"}))
+	text := []rune("ไปฮ中文ข้าว")
+
+	expect := []string{"ไป", "ฮ中文", "ข้าว"}
+	if got := sm.Segment(text); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+
+	sm.HardBoundaries = []*unicode.RangeTable{unicode.Han}
+	expect = []string{"ไป", "ฮ", "中文", "ข้าว"}
+	if got := sm.Segment(text); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+}
+
+func TestSegmentMatchLengths(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป", "กิน", "ข้าว"}))
+
+	tokens, lengths := sm.SegmentMatchLengths([]rune("ไปฮฮกินข้าว ok"))
+	if expect := []string{"ไป", "ฮฮ", "กิน", "ข้าว", " ", "ok"}; !reflect.DeepEqual(expect, tokens) {
+		t.Errorf("Expect %v got %v", expect, tokens)
+	}
+	if expect := []int{2, 0, 3, 4, 0, 0}; !reflect.DeepEqual(expect, lengths) {
+		t.Errorf("Expect %v got %v", expect, lengths)
+	}
+}
+
+func TestSegmentBoundaries(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป", "กิน", "ข้าว"}))
+	text := []rune("ไปกินข้าว กับ ฮฮ")
+
+	bounds := sm.Boundaries(text)
+	if bounds[0] != 0 || bounds[len(bounds)-1] != len(text) {
+		t.Errorf("Expect boundaries from 0 to %d got %v", len(text), bounds)
+	}
+
+	var tokens []string
+	for i := 1; i < len(bounds); i++ {
+		tokens = append(tokens, string(text[bounds[i-1]:bounds[i]]))
+	}
+	if expect := sm.Segment(text); !reflect.DeepEqual(expect, tokens) {
+		t.Errorf("Expect %v got %v", expect, tokens)
+	}
+
+	if expect := []int{0}; !reflect.DeepEqual(expect, sm.Boundaries(nil)) {
+		t.Errorf("Expect %v got %v", expect, sm.Boundaries(nil))
+	}
+}
+
+func TestSegmentBoundaryAt(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป", "กิน", "ข้าว"}))
+	text := []rune("ไปกินข้าว")
+
+	for i := -1; i <= len(text)+1; i++ {
+		expect := i == 0 || i == 2 || i == 5 || i == 9
+		if got := sm.BoundaryAt(text, i); got != expect {
+			t.Errorf("Expect boundary at %d %v got %v", i, expect, got)
 		}
 	}
 }
+
+func TestSegmentWithBoundaries(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป", "กิน", "ข้าว", "กินข้าว"}))
+	text := []rune("ไปกินข้าว")
+
+	tests := []struct {
+		fixed  []int
+		expect []string
+	}{
+		{nil, []string{"ไป", "กินข้าว"}},
+		{[]int{5}, []string{"ไป", "กิน", "ข้าว"}},
+		{[]int{7, 5, 5, -1, 12}, []string{"ไป", "กิน", "ข้", "าว"}},
+		{[]int{0, 9}, []string{"ไป", "กินข้าว"}},
+	}
+	for _, test := range tests {
+		if got := sm.SegmentWithBoundaries(text, test.fixed); !reflect.DeepEqual(test.expect, got) {
+			t.Errorf("Expect %v with boundaries %v got %v", test.expect, test.fixed, got)
+		}
+	}
+}
+
+func TestSegmentBIOTags(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป", "กิน", "ข้าว"}))
+
+	expect := "BIBIIBIIIBBI"
+	if got := string(sm.BIOTags([]rune("ไปกินข้าว ฮฮ"))); got != expect {
+		t.Errorf("Expect %s got %s", expect, got)
+	}
+
+	if got := sm.BIOTags(nil); len(got) != 0 {
+		t.Errorf("Expect no tags got %s", got)
+	}
+
+	sm.Boundary = '|'
+	if got := string(sm.BIOTags([]rune("ไป|กิน"))); got != "BIOBII" {
+		t.Errorf("Expect BIOBII got %s", got)
+	}
+	if expect, got := []int{0, 2, 3, 6}, sm.Boundaries([]rune("ไป|กิน")); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+}
+
+func TestOverrideIsSpace(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป", "กิน"}))
+	text := []rune("ไป \"กิน\"")
+
+	expect := []string{"ไป", " \"", "กิน", "\""}
+	if got := sm.Segment(text); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+
+	defer func() { IsSpace = DefaultIsSpace }()
+	IsSpace = func(ch rune) bool {
+		return ch != '"' && ch != '“' && ch != '”' && DefaultIsSpace(ch)
+	}
+
+	expect = []string{"ไป", " ", "\"", "กิน", "\""}
+	if got := sm.Segment(text); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+	if IsSpaceToken("\"") {
+		t.Error("Expect quote no longer a space token")
+	}
+}
+
+func TestSegmentToSpaced(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป", "กิน", "ข้าว", "ที่", "บ้าน"}))
+
+	if got, expect := sm.SegmentToSpaced("ไปกินข้าว ที่บ้าน"), "ไป กิน ข้าว   ที่ บ้าน"; got != expect {
+		t.Errorf("Expect %q got %q", expect, got)
+	}
+
+	sm.CollapseSpaces = true
+	if got, expect := sm.SegmentToSpaced(" ไปกินข้าว  ที่บ้าน"), "ไป กิน ข้าว ที่ บ้าน"; got != expect {
+		t.Errorf("Expect %q got %q", expect, got)
+	}
+}
+
+func TestNewSegmenterFromWords(t *testing.T) {
+	sm := NewSegmenterFromWords([]string{"ไป", "กิน", "ข้าว"})
+
+	expect := []string{"ไป", "กิน", "ข้าว"}
+	if got := sm.Segment([]rune("ไปกินข้าว")); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+}
+
+func TestSegmentCostTable(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป", "กิน", "ข้าว"}))
+	text := []rune("ไปฮฮกินข้าว abc")
+
+	table := sm.CostTable(text)
+	if len(table) != len(text)+1 {
+		t.Fatalf("Expect %d edges got %d", len(text)+1, len(table))
+	}
+
+	for e := len(text); e > 0; e = table[e].S {
+		s := table[e].S
+		if table[s].WordCount > table[e].WordCount || table[s].UnkCount > table[e].UnkCount {
+			t.Errorf("Expect counts not to decrease from %d %+v to %d %+v", s, table[s], e, table[e])
+		}
+	}
+	if last := table[len(text)]; last.WordCount != 6 || last.UnkCount != 1 {
+		t.Errorf("Expect 6 words and 1 unknown got %+v", last)
+	}
+}
+
+func TestSegmentFunc(t *testing.T) {
+	dict := MakePrefixTree([]string{"ไป", "กิน", "ข้าว"})
+
+	expect := []string{"ไป", "กิน", "ข้าว"}
+	if got := Segment(dict, "ไปกินข้าว"); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+}
+
+func TestSegmentRange(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป", "กิน", "ข้าว", "นอน"}))
+	runes := []rune("นอนไปกินข้าวนอน")
+
+	sub := make([]rune, 9)
+	copy(sub, runes[3:12])
+	expect := sm.Segment(sub)
+
+	if got := sm.SegmentRange(runes, 3, 12); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+
+	tokens, offsets := sm.SegmentRangeOffsets(runes, 3, 12)
+	if !reflect.DeepEqual(expect, tokens) {
+		t.Errorf("Expect %v got %v", expect, tokens)
+	}
+	if expect := []int{3, 5, 8}; !reflect.DeepEqual(expect, offsets) {
+		t.Errorf("Expect offsets %v got %v", expect, offsets)
+	}
+}
+
+// mostWords prefers the edge with more words
+type mostWords struct{}
+
+func (mostWords) Better(a, b Edge) bool {
+	return a.UnkCount < b.UnkCount ||
+		(a.UnkCount == b.UnkCount && a.WordCount > b.WordCount)
+}
+
+func TestSegmentComparator(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"กิน", "ข้าว", "กินข้าว"}))
+	text := []rune("กินข้าว")
+
+	sm.Comparator = DefaultEdgeComparator{}
+	expect := []string{"กินข้าว"}
+	if got := sm.Segment(text); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+
+	sm.Comparator = mostWords{}
+	expect = []string{"กิน", "ข้าว"}
+	if got := sm.Segment(text); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+
+	sm.LengthBonus = 10
+	if got := sm.Segment(text); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect LengthBonus to be ignored with a Comparator, expect %v got %v", expect, got)
+	}
+}
+
+func TestSegmentMarkUnknown(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป", "กิน", "ข้าว"}))
+	sm.MarkUnknown = true
+
+	expect := []string{"ไป", "<UNK:ฮฮ>", "กิน", "ข้าว", " ", "abc"}
+	if got := sm.Segment([]rune("ไปฮฮกินข้าว abc")); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+
+	tokens, unknown := sm.SegmentUnknown([]rune("ไปฮฮ"))
+	if expect := []string{"ไป", "<UNK:ฮฮ>"}; !reflect.DeepEqual(expect, tokens) {
+		t.Errorf("Expect %v got %v", expect, tokens)
+	}
+	if expect := []bool{false, true}; !reflect.DeepEqual(expect, unknown) {
+		t.Errorf("Expect %v got %v", expect, unknown)
+	}
+}
+
+func TestSegmentUnkDeltas(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป", "กิน", "ข้าว"}))
+
+	tokens, deltas := sm.SegmentUnkDeltas([]rune("ไปฮฮกินข้าว abc"))
+	if expect := []string{"ไป", "ฮฮ", "กิน", "ข้าว", " ", "abc"}; !reflect.DeepEqual(expect, tokens) {
+		t.Errorf("Expect %v got %v", expect, tokens)
+	}
+	if expect := []int{0, 1, 0, 0, 0, 0}; !reflect.DeepEqual(expect, deltas) {
+		t.Errorf("Expect %v got %v", expect, deltas)
+	}
+}
+
+func TestSegmentSpansFollowOptions(t *testing.T) {
+	dict := MakePrefixTree([]string{"ไป", "กิน", "ข้าว", "น้ำ"})
+	tests := []struct {
+		name   string
+		set    func(sm *Segmenter)
+		text   string
+		expect []string
+	}{
+		{"Boundary", func(sm *Segmenter) { sm.Boundary = '|' }, "ไปกิน|ฮฮข้าว", []string{"ไป", "กิน", "ฮฮ", "ข้าว"}},
+		{"SentenceSep", func(sm *Segmenter) { sm.SentenceSep = "<s>" }, "ไปฮฮ<s>กิน", []string{"ไป", "ฮฮ", "<s>", "กิน"}},
+		{"LooseMatch", func(sm *Segmenter) { sm.SetDict(dict.Loose()); sm.LooseMatch = true }, "ไปนํ้าฮ", []string{"ไป", "นํ้า", "ฮ"}},
+		{"MarkUnknown", func(sm *Segmenter) { sm.MarkUnknown = true }, "ไปฮฮ กิน", []string{"ไป", "<UNK:ฮฮ>", " ", "กิน"}},
+	}
+	for _, test := range tests {
+		sm := NewSegmenter(dict)
+		test.set(sm)
+		text := []rune(test.text)
+
+		if got := sm.Segment(text); !reflect.DeepEqual(test.expect, got) {
+			t.Errorf("%s: Expect %v got %v", test.name, test.expect, got)
+		}
+
+		tokens, offsets := sm.SegmentOffsets(text)
+		if !reflect.DeepEqual(test.expect, tokens) {
+			t.Errorf("%s: Expect offsets tokens %v got %v", test.name, test.expect, tokens)
+		}
+		unknown := []rune("ฮ")[0]
+		for i, offset := range offsets {
+			if want := []rune(strings.TrimPrefix(test.expect[i], "<UNK:"))[0]; text[offset] != want {
+				t.Errorf("%s: Expect token %d at %d to start with %c got %c", test.name, i, offset, want, text[offset])
+			}
+		}
+
+		tokens, flags := sm.SegmentUnknown(text)
+		if !reflect.DeepEqual(test.expect, tokens) {
+			t.Errorf("%s: Expect unknown tokens %v got %v", test.name, test.expect, tokens)
+		}
+		tokens, deltas := sm.SegmentUnkDeltas(text)
+		if !reflect.DeepEqual(test.expect, tokens) {
+			t.Errorf("%s: Expect delta tokens %v got %v", test.name, test.expect, tokens)
+		}
+		for i, token := range test.expect {
+			isUnknown := strings.ContainsRune(token, unknown)
+			if flags[i] != isUnknown || (deltas[i] == 1) != isUnknown {
+				t.Errorf("%s: Expect %q unknown %v got %v and delta %d", test.name, token, isUnknown, flags[i], deltas[i])
+			}
+		}
+	}
+}
+
+func TestSegmentSentenceSep(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป", "กิน", "ข้าว", "ไปกิน"}))
+	sm.SentenceSep = "<s>"
+
+	expect := []string{"กิน", "ข้าว", "ไป", "<s>", "กิน", "ข้าว"}
+	if got := sm.Segment([]rune("กินข้าวไป<s>กินข้าว")); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+}
+
+func TestSegmentWordOrder(t *testing.T) {
+	words := []string{"ตา", "กลม", "ตาก", "ลม"}
+	sm := NewSegmenter(MakePrefixTree(words))
+	text := []rune("ตากลม")
+
+	sm.SetWordOrder(words)
+	expect := []string{"ตา", "กลม"}
+	if got := sm.Segment(text); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+
+	sm.SetWordOrder([]string{"ตาก", "ลม", "ตา", "กลม"})
+	expect = []string{"ตาก", "ลม"}
+	if got := sm.Segment(text); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+
+	// the order is ranked again in a dictionary of other node IDs
+	swapped := MakePrefixTree(append([]string{"กา", "ตะ", "ตาม", "ลมบก"}, words...))
+	sm.SetDict(swapped)
+	if got := sm.Segment(text); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v after SetDict got %v", expect, got)
+	}
+	sm.SetDictChain(DictChain{swapped})
+	if got := sm.Segment(text); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v after SetDictChain got %v", expect, got)
+	}
+	if len(sm.ranks) != 4 {
+		t.Errorf("Expect 4 ranked words got %d", len(sm.ranks))
+	}
+
+	sm.SetWordOrder(nil)
+	expect = []string{"ตา", "กลม"}
+	if got := sm.Segment(text); !reflect.DeepEqual(expect, got) || sm.ranks != nil {
+		t.Errorf("Expect %v without an order got %v", expect, got)
+	}
+}
+
+func TestSegmentWordOrderMinimized(t *testing.T) {
+	words := []string{"ตา", "กลม", "ตาก", "ลม", "มา", "ลา"}
+	order := []string{"ตาก", "ลม", "ตา", "กลม"}
+	plain := MakePrefixTree(words)
+	minimized := plain.Minimize()
+
+	for _, text := range []string{"ตากลม", "ตากลมมา", "ลาตากลม"} {
+		sm := NewSegmenter(plain)
+		sm.SetWordOrder(order)
+		expect := sm.Segment([]rune(text))
+
+		sm = NewSegmenter(minimized)
+		sm.SetWordOrder(order)
+		if got := sm.Segment([]rune(text)); !reflect.DeepEqual(expect, got) {
+			t.Errorf("Expect minimized %v like the plain tree got %v", expect, got)
+		}
+	}
+}
+
+func TestSegmentLengthBonus(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ข้าว", "มัน", "ไก่", "ข้าวมัน", "มันไก่", "ก", "ไป"}))
+	text := []rune("ข้าวมันไก่")
+
+	// longest is the rune length of the longest token
+	longest := func(tokens []string) int {
+		n := 0
+		for _, token := range tokens {
+			if l := len([]rune(token)); l > n {
+				n = l
+			}
+		}
+		return n
+	}
+
+	plain := sm.Segment(text)
+	if expect := []string{"ข้าว", "มันไก่"}; !reflect.DeepEqual(expect, plain) {
+		t.Errorf("Expect %v got %v", expect, plain)
+	}
+
+	for _, bonus := range []float64{0.001, 0.5, 10} {
+		sm.LengthBonus = bonus
+
+		// the default already has the fewest words, the bonus picks the
+		// longer words among the splits of as many words
+		got := sm.Segment(text)
+		if expect := []string{"ข้าวมัน", "ไก่"}; !reflect.DeepEqual(expect, got) {
+			t.Errorf("Expect %v with bonus %v got %v", expect, bonus, got)
+		}
+		if len(got) != len(plain) || longest(got) != 7 || longest(plain) != 6 {
+			t.Errorf("Expect %d tokens up to 7 runes long with bonus %v, 6 without, got %v and %v", len(plain), bonus, got, plain)
+		}
+
+		// dictionary words are never absorbed into an unknown word
+		expect := []string{"ไป", "ก", "ข"}
+		if got := sm.Segment([]rune("ไปกข")); !reflect.DeepEqual(expect, got) {
+			t.Errorf("Expect %v with bonus %v got %v", expect, bonus, got)
+		}
+	}
+}
+
+func TestSegmentKeepDelimIdempotent(t *testing.T) {
+	w := &SegmenterWorker{
+		dict:      MakePrefixTree([]string{"ไป", "กิน", "ข้าว"}),
+		KeepDelim: true,
+	}
+	sm := w.newSegmenter()
+
+	once := w.SegmentLine(sm, []rune("ไปกินข้าว ไป"))
+	twice := w.SegmentLine(sm, []rune(once))
+
+	if expect := "ไป|กิน|ข้าว| |ไป"; once != expect {
+		t.Errorf("Expect %q got %q", expect, once)
+	}
+	if once != twice {
+		t.Errorf("Expect re-segmenting %q to be unchanged got %q", once, twice)
+	}
+}
+
+func TestSwapDictWhileSegmenting(t *testing.T) {
+	oldDict := MakePrefixTree([]string{"ไป", "กิน", "ข้า"})
+	newDict := MakePrefixTree([]string{"ไป", "กิน", "ข้าว"})
+	w := &SegmenterWorker{dict: oldDict}
+
+	input := strings.Repeat("ไปกินข้าว\n", 2000)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			if i%2 == 0 {
+				w.SwapDict(newDict)
+			} else {
+				w.SwapDict(oldDict)
+			}
+		}
+		w.SwapDict(newDict)
+	}()
+
+	var out bytes.Buffer
+	if err := w.RunIO(strings.NewReader(input), &out); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+		if line != "ไป|กิน|ข้าว" && line != "ไป|กิน|ข้า|ว" {
+			t.Fatalf("Expect line segmented with either dictionary got %q", line)
+		}
+	}
+
+	w2 := &SegmenterWorker{dict: oldDict}
+	w2.SwapDict(newDict)
+	out.Reset()
+	if err := w2.RunIO(strings.NewReader("ไปกินข้าว\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+	if expect := "ไป|กิน|ข้าว\n"; out.String() != expect {
+		t.Errorf("Expect %q got %q", expect, out.String())
+	}
+}
+
+func TestWorkerKeepsBlankLines(t *testing.T) {
+	w := NewSegmenterWorkerFromWords([]string{"ไป", "กิน", "ข้าว"})
+	w.Stopwords = map[string]struct{}{"ไป": {}}
+
+	var out bytes.Buffer
+	if err := w.RunIO(strings.NewReader("\nกินข้าว\n\n\nไป\nไปกิน\n\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	if expect := "\nกิน|ข้าว\n\n\n\nกิน\n\n"; out.String() != expect {
+		t.Errorf("Expect %q got %q", expect, out.String())
+	}
+}
+
+func TestWorkerRunIOTwice(t *testing.T) {
+	w := NewSegmenterWorkerFromWords([]string{"ไป", "กิน", "ข้าว"})
+
+	for _, test := range []struct{ in, expect string }{
+		{"ไปกินข้าว\nกิน\n", "ไป|กิน|ข้าว\nกิน\n"},
+		{"กินข้าว\n", "กิน|ข้าว\n"},
+	} {
+		var out bytes.Buffer
+		done := make(chan error, 1)
+		go func() { done <- w.RunIO(strings.NewReader(test.in), &out) }()
+
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expect RunIO to return")
+		}
+		if out.String() != test.expect {
+			t.Errorf("Expect %q got %q", test.expect, out.String())
+		}
+	}
+}
+
+func TestWorkerEOS(t *testing.T) {
+	w := NewSegmenterWorkerFromWords([]string{"ไป", "กิน", "ข้าว"})
+	w.EOS = "</s>"
+
+	var out bytes.Buffer
+	if err := w.RunIO(strings.NewReader("ไปกินข้าว\nกิน\n\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+		tokens := strings.Split(line, "|")
+		if strings.Count(line, w.EOS) != 1 || tokens[len(tokens)-1] != w.EOS {
+			t.Errorf("Expect %q once at the end of %q", w.EOS, line)
+		}
+	}
+	if expect := "ไป|กิน|ข้าว|</s>\nกิน|</s>\n</s>\n"; out.String() != expect {
+		t.Errorf("Expect %q got %q", expect, out.String())
+	}
+
+	// every output mode gets the marker
+	tests := []struct {
+		set    func(w *SegmenterWorker)
+		expect string
+	}{
+		{func(w *SegmenterWorker) { w.HTML = true }, `<span class="tok">กิน</span><span class="tok">&lt;/s&gt;</span>` + "\n"},
+		{func(w *SegmenterWorker) { w.BEST = true }, "กิน|</s>\n"},
+		{func(w *SegmenterWorker) { w.TokenRecords = true }, `{"line":0,"index":0,"start":0,"token":"กิน"}` + "\n" +
+			`{"line":0,"index":1,"start":3,"token":"\u003c/s\u003e"}` + "\n"},
+		{func(w *SegmenterWorker) { w.FieldDelim = "\t"; w.Columns = []int{1} }, "กิน\tกิน|</s>\n"},
+	}
+	for _, test := range tests {
+		w := NewSegmenterWorkerFromWords([]string{"ไป", "กิน", "ข้าว"})
+		w.EOS = "</s>"
+		test.set(w)
+		in := "กิน\n"
+		if w.FieldDelim != "" {
+			in = "กิน\tกิน\n"
+		}
+
+		var out bytes.Buffer
+		if err := w.RunIO(strings.NewReader(in), &out); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != test.expect {
+			t.Errorf("Expect %q got %q", test.expect, out.String())
+		}
+	}
+}
+
+func TestWorkerReverseTokens(t *testing.T) {
+	w := NewSegmenterWorkerFromWords([]string{"ไป", "กิน", "ข้าว"})
+	w.ReverseTokens = true
+
+	var out bytes.Buffer
+	if err := w.RunIO(strings.NewReader("ไปกินข้าว abc\nกิน\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	if expect := "abc| |ข้าว|กิน|ไป\nกิน\n"; out.String() != expect {
+		t.Errorf("Expect %q got %q", expect, out.String())
+	}
+
+	w.HTML = true
+	out.Reset()
+	if err := w.RunIO(strings.NewReader("ไปกิน\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+	if expect := `<span class="tok">กิน</span><span class="tok">ไป</span>` + "\n"; out.String() != expect {
+		t.Errorf("Expect %q got %q", expect, out.String())
+	}
+
+	w.HTML = false
+	w.TokenRecords = true
+	out.Reset()
+	if err := w.RunIO(strings.NewReader("ไปกิน\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+	expect := `{"line":0,"index":0,"start":2,"token":"กิน"}` + "\n" +
+		`{"line":0,"index":1,"start":0,"token":"ไป"}` + "\n"
+	if out.String() != expect {
+		t.Errorf("Expect %q got %q", expect, out.String())
+	}
+}
+
+func TestWorkerOutputBufferSize(t *testing.T) {
+	in := strings.Repeat("ไปกินข้าว\nกิน ข้าว\n", 50)
+
+	var expect bytes.Buffer
+	if err := NewSegmenterWorkerFromWords([]string{"ไป", "กิน", "ข้าว"}).RunIO(strings.NewReader(in), &expect); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, size := range []int{16, 1 << 16} {
+		w := NewSegmenterWorkerFromWords([]string{"ไป", "กิน", "ข้าว"})
+		w.OutputBufferSize = size
+
+		var out bytes.Buffer
+		if err := w.RunIO(strings.NewReader(in), &out); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != expect.String() {
+			t.Errorf("Expect the same output with a buffer of %d bytes got %q", size, out.String())
+		}
+	}
+}
+
+func TestWorkerMaxInputBytes(t *testing.T) {
+	w := NewSegmenterWorkerFromWords([]string{"ไป", "กิน", "ข้าว"})
+	w.MaxInputBytes = 10
+
+	var out bytes.Buffer
+	err := w.RunIO(strings.NewReader("ไปกินข้าว\n"), &out)
+	if err == nil || !strings.Contains(err.Error(), "10 bytes") {
+		t.Errorf("Expect input size error got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expect no output got %q", out.String())
+	}
+
+	w = NewSegmenterWorkerFromWords([]string{"ไป"})
+	w.MaxInputBytes = 7
+	out.Reset()
+	if err := w.RunIO(strings.NewReader("ไป\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+	if expect := "ไป\n"; out.String() != expect {
+		t.Errorf("Expect %q got %q", expect, out.String())
+	}
+}
+
+// slowComparator sleeps before every comparison
+type slowComparator struct {
+	delay time.Duration
+}
+
+func (c slowComparator) Better(a, b Edge) bool {
+	time.Sleep(c.delay)
+	return DefaultEdgeComparator{}.Better(a, b)
+}
+
+func TestWorkerLineTimeout(t *testing.T) {
+	w := NewSegmenterWorkerFromWords([]string{"ไป", "กิน", "ข้าว", "กินข้าว"})
+	w.Comparator = slowComparator{time.Millisecond}
+	w.LineTimeout = 20 * time.Millisecond
+
+	baseline := runtime.NumGoroutine()
+	slow := strings.Repeat("กินข้าว", 100)
+
+	start := time.Now()
+	var out bytes.Buffer
+	if err := w.RunIO(strings.NewReader("ไปนอน\n"+slow+"\nไป\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expect the slow line to be stopped got %v", elapsed)
+	}
+
+	if expect := "ไป|นอน\n" + slow + "\nไป\n"; out.String() != expect {
+		t.Errorf("Expect %q got %q", expect, out.String())
+	}
+	if expect := []int{1}; !reflect.DeepEqual(expect, w.TimedOut()) {
+		t.Errorf("Expect timed out lines %v got %v", expect, w.TimedOut())
+	}
+
+	// the workers exit after RunIO, no segmentation is left running
+	for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() > baseline; {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expect %d goroutines got %d", baseline, runtime.NumGoroutine())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSegmentDeadline(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป", "กิน"}))
+
+	sm.SetDeadline(time.Now().Add(-time.Second))
+	expect := []string{"ไปกิน"}
+	if got := sm.Segment([]rune("ไปกิน")); !reflect.DeepEqual(expect, got) || !sm.DeadlineExceeded() {
+		t.Errorf("Expect %v after the deadline got %v", expect, got)
+	}
+
+	sm.SetDeadline(time.Time{})
+	expect = []string{"ไป", "กิน"}
+	if got := sm.Segment([]rune("ไปกิน")); !reflect.DeepEqual(expect, got) || sm.DeadlineExceeded() {
+		t.Errorf("Expect %v without a deadline got %v", expect, got)
+	}
+}
+
+func TestSegmentDictWordWithSpace(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป", "กรุงเทพ", "กรุงเทพ มหานคร", "กิน", "ข้าว"}))
+
+	expect := []string{"ไป", "กรุงเทพ มหานคร"}
+	if got := sm.Segment([]rune("ไปกรุงเทพ มหานคร")); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+
+	expect = []string{"ไป", "กรุงเทพ", " ", "กิน", "ข้าว"}
+	if got := sm.Segment([]rune("ไปกรุงเทพ กินข้าว")); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+}
+
+func TestSegmentSpaceBoundaries(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป", "กรุงเทพ", "กรุงเทพ มหานคร", "มหานคร"}))
+	sm.SpaceBoundaries = true
+	sm.MixedWords = true
+
+	expect := []string{"ไป", "กรุงเทพ", " ", "มหานคร"}
+	if got := sm.Segment([]rune("ไปกรุงเทพ มหานคร")); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+}
+
+func TestSegmentPointersDoNotCrossLatin(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"กิน", "ข้าว", "กินข้าว"}))
+
+	expect := []string{"กิน", "abc", "ข้าว"}
+	if got := sm.Segment([]rune("กินabcข้าว")); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+}
+
+func TestSegmentWithPath(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป", "กิน", "ข้าว"}))
+	text := []rune("ไปกินข้าว")
+
+	tokens, path := sm.SegmentWithPath(text)
+	if len(path) != len(text)+1 {
+		t.Fatalf("Expect path length %d got %d", len(text)+1, len(path))
+	}
+	if last := path[len(text)]; last.WordCount != len(tokens) || last.UnkCount != 0 {
+		t.Errorf("Expect %d words and no unknown got %v", len(tokens), last)
+	}
+
+	sm.Segment([]rune("ข้าว"))
+	if path[len(text)].WordCount != 3 {
+		t.Errorf("Expect path copy not to change got %v", path[len(text)])
+	}
+}
+
+func TestSegmentUnicodeSpaces(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป"}))
+	text := []rune("ไปแมว\u200bหมา")
+
+	expect := []string{"ไป", "แมว\u200bหมา"}
+	if got := sm.Segment(text); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %q got %q", expect, got)
+	}
+
+	sm.UnicodeSpaces = true
+
+	expect = []string{"ไป", "แมว", "\u200b", "หมา"}
+	if got := sm.Segment(text); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %q got %q", expect, got)
+	}
+
+	// the U+200B token is a space token everywhere else too
+	sm.CollapseSpaces = true
+	if got, expect := sm.SegmentToSpaced(string(text)), "ไป แมว หมา"; got != expect {
+		t.Errorf("Expect %q got %q", expect, got)
+	}
+	stats, err := sm.SegmentStats(strings.NewReader(string(text)), true)
+	if err != nil || stats.Count != 3 {
+		t.Errorf("Expect 3 tokens without spaces got %d, %v", stats.Count, err)
+	}
+	if tokens := sm.SegmentTokens(text); tokens[2].Type != Space {
+		t.Errorf("Expect %q of type Space got %v", tokens[2].Text, tokens[2].Type)
+	}
+	_, ends := sm.SegmentSentenceEnds(text)
+	if expect := []bool{false, true, false, true}; !reflect.DeepEqual(expect, ends) {
+		t.Errorf("Expect %v got %v", expect, ends)
+	}
+}
+
+func TestSegmentMixedWords(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"โควิด", "โควิด-19", "ระบาด", "ไอโฟนX"}))
+
+	expect := []string{"โควิด", "-", "19", "ระบาด"}
+	if got := sm.Segment([]rune("โควิด-19ระบาด")); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+
+	sm.MixedWords = true
+
+	tests := []struct {
+		text   string
+		expect []string
+	}{
+		{"โควิด-19ระบาด", []string{"โควิด-19", "ระบาด"}},
+		{"ระบาดโควิด-19", []string{"ระบาด", "โควิด-19"}},
+		{"โควิด-20ระบาด", []string{"โควิด", "-", "20", "ระบาด"}},
+		{"ไอโฟนXระบาด", []string{"ไอโฟนX", "ระบาด"}},
+	}
+	for _, test := range tests {
+		if got := sm.Segment([]rune(test.text)); !reflect.DeepEqual(test.expect, got) {
+			t.Errorf("Expect %v got %v", test.expect, got)
+		}
+	}
+}
+
+func TestSegmentJoiners(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป", "มา", "ไป+มา"}))
+
+	tests := []struct {
+		text    string
+		without []string
+		with    []string
+	}{
+		{"foo_barไป", []string{"foo", "_", "bar", "ไป"}, []string{"foo_bar", "ไป"}},
+		// the joined text run matches a dictionary word with a joiner
+		{"ไป+มา", []string{"ไป", "+", "มา"}, []string{"ไป+มา"}},
+		{"ไปก+ข", []string{"ไป", "ก", "+", "ข"}, []string{"ไป", "ก+ข"}},
+		{"a-b-c d__e", []string{"a", "-", "b", "-", "c", " ", "d", "__", "e"}, []string{"a-b-c", " ", "d__e"}},
+		{"foo_ไป", []string{"foo", "_", "ไป"}, []string{"foo", "_", "ไป"}},
+		{"ไป-foo", []string{"ไป", "-", "foo"}, []string{"ไป", "-", "foo"}},
+	}
+	for _, test := range tests {
+		sm.Joiners = ""
+		if got := sm.Segment([]rune(test.text)); !reflect.DeepEqual(test.without, got) {
+			t.Errorf("Expect %q without joiners got %q", test.without, got)
+		}
+		sm.Joiners = "_-+"
+		if got := sm.Segment([]rune(test.text)); !reflect.DeepEqual(test.with, got) {
+			t.Errorf("Expect %q with joiners got %q", test.with, got)
+		}
+	}
+}
+
+func TestSegmentClassifiers(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป"}))
+	text := []rune("#golang 50%ไป")
+
+	expect := []string{"#", "golang", " ", "50", "%", "ไป"}
+	if got := sm.Segment(text); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %q got %q", expect, got)
+	}
+
+	hashtag := func(ch rune) (WordType, bool) {
+		return Latin, ch == '#'
+	}
+	percent := func(ch rune) (WordType, bool) {
+		return Number, ch == '%' || ch == '#'
+	}
+	sm.Classifiers = []RuneClassifier{hashtag, percent}
+
+	expect = []string{"#golang", " ", "50%", "ไป"}
+	if got := sm.Segment(text); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %q got %q", expect, got)
+	}
+}
+
+func TestSegmentGroupUnknown(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป"}))
+	text := []rune("ไปฮฮฮไป")
+
+	expect := []string{"ไป", "ฮฮฮ", "ไป"}
+	if got := sm.Segment(text); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+
+	sm.GroupUnknown = false
+
+	expect = []string{"ไป", "ฮ", "ฮ", "ฮ", "ไป"}
+	if got := sm.Segment(text); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+	if unk := sm.path[len(text)].UnkCount; unk != 3 {
+		t.Errorf("Expect UnkCount 3 per character got %d", unk)
+	}
+
+	sm.GroupUnknown = true
+	expect = []string{"ไป", "ฮฮฮ", "ไป"}
+	if got := sm.Segment(text); !reflect.DeepEqual(expect, got) || sm.path[len(text)].UnkCount != 1 {
+		t.Errorf("Expect %v grouped got %v", expect, got)
+	}
+}
+
+func TestSegmentUnknownChunk(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป"}))
+	sm.UnknownChunk = 3
+
+	expect := []string{"ไป", "ฮฮฮ", "ฮฮฮ", "ฮ", "ไป"}
+	if got := sm.Segment([]rune("ไปฮฮฮฮฮฮฮไป")); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+}
+
+func TestLoadDictGzip(t *testing.T) {
+	var b bytes.Buffer
+	zw := gzip.NewWriter(&b)
+	zw.Write([]byte("ไป\nกิน\nข้าว\n"))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "dict.txt.gz")
+	if err := os.WriteFile(path, b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dict, err := LoadDict(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sm := NewSegmenter(dict)
+	expect := []string{"ไป", "กิน", "ข้าว"}
+	if got := sm.Segment([]rune("ไปกินข้าว")); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+}
+
+func TestBOMIsStripped(t *testing.T) {
+	dict, err := LoadDictReader(strings.NewReader("\ufeffไป\nกิน\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dict.IsWordStart('ไ') {
+		t.Errorf("Expect first dictionary word without BOM")
+	}
+
+	w := &SegmenterWorker{dict: dict}
+	var out bytes.Buffer
+	if err := w.RunIO(strings.NewReader("\ufeffไปกิน\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+	if expect := "ไป|กิน\n"; out.String() != expect {
+		t.Errorf("Expect %q got %q", expect, out.String())
+	}
+}
+
+func TestNewSegmenterWorkerFromWords(t *testing.T) {
+	w := NewSegmenterWorkerFromWords([]string{"ไป", "", "กิน", "ข้าว"})
+
+	var out bytes.Buffer
+	if err := w.RunIO(strings.NewReader("ไปกินข้าว\nกินข้าว\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+	if expect := "ไป|กิน|ข้าว\nกิน|ข้าว\n"; out.String() != expect {
+		t.Errorf("Expect %q got %q", expect, out.String())
+	}
+}
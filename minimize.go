@@ -0,0 +1,84 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// trieState is a node of the prefix tree, the same NodeID is reused on
+// different offsets so a node is identified by both
+type trieState struct {
+	NodeID int
+	Offset int
+}
+
+type trieEdge struct {
+	Ch rune
+	PrefixTreePointer
+}
+
+// Minimize merges equivalent suffix subtrees into a DAWG. Nodes are only
+// merged with nodes at the same offset, so lookups work the same as on the
+// original tree.
+func (t PrefixTree) Minimize() PrefixTree {
+	edges := make(map[trieState][]trieEdge)
+	maxOffset := 0
+	for node, child := range t {
+		state := trieState{node.NodeID, node.Offset}
+		edges[state] = append(edges[state], trieEdge{node.Ch, child})
+
+		// leaves have no edge of their own
+		leaf := trieState{child.ChildID, node.Offset + 1}
+		if _, found := edges[leaf]; !found {
+			edges[leaf] = nil
+		}
+		if leaf.Offset > maxOffset {
+			maxOffset = leaf.Offset
+		}
+	}
+
+	byOffset := make([][]trieState, maxOffset+1)
+	for state, es := range edges {
+		sort.Slice(es, func(i, j int) bool { return es[i].Ch < es[j].Ch })
+		byOffset[state.Offset] = append(byOffset[state.Offset], state)
+	}
+
+	canon := make(map[trieState]int, len(edges))
+	minimized := make(PrefixTree)
+
+	// deepest offset first so children are canonical before their parents
+	for offset := maxOffset; offset >= 0; offset-- {
+		states := byOffset[offset]
+		sort.Slice(states, func(i, j int) bool { return states[i].NodeID < states[j].NodeID })
+
+		registry := make(map[string]int)
+		for _, state := range states {
+			var sig strings.Builder
+			for _, e := range edges[state] {
+				sig.WriteString(strconv.Itoa(int(e.Ch)))
+				sig.WriteByte(':')
+				sig.WriteString(strconv.FormatBool(e.IsFinal))
+				sig.WriteByte(':')
+				sig.WriteString(strconv.Itoa(canon[trieState{e.ChildID, offset + 1}]))
+				sig.WriteByte(';')
+			}
+
+			if id, found := registry[sig.String()]; found {
+				canon[state] = id
+				continue
+			}
+			registry[sig.String()] = state.NodeID
+			canon[state] = state.NodeID
+
+			for _, e := range edges[state] {
+				minimized[PrefixTreeNode{state.NodeID, offset, e.Ch}] = PrefixTreePointer{
+					ChildID: canon[trieState{e.ChildID, offset + 1}],
+					IsFinal: e.IsFinal,
+				}
+			}
+		}
+	}
+
+	return minimized
+}
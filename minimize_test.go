@@ -0,0 +1,65 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMinimizeMergesSuffixes(t *testing.T) {
+	dict := MakePrefixTree([]string{"กาก", "ขาก", "คาก"})
+	minimized := dict.Minimize()
+
+	if len(minimized) >= len(dict) {
+		t.Errorf("Expect fewer than %d nodes got %d", len(dict), len(minimized))
+	}
+
+	for _, word := range []string{"กาก", "ขาก", "คาก"} {
+		runes := []rune(word)
+		if got := minimized.LongestMatchAt(runes, 0); got != len(runes) {
+			t.Errorf("Expect to match %s got length %d", word, got)
+		}
+	}
+
+	if got := minimized.LongestMatchAt([]rune("กา"), 0); got != 0 {
+		t.Errorf("Expect not to match กา got length %d", got)
+	}
+}
+
+func TestMinimizeSegmentsIdentically(t *testing.T) {
+	dict, err := LoadDefaultDict()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	original := NewSegmenter(dict)
+	minimized := NewSegmenter(dict.Minimize())
+
+	runes := []rune(benchParagraph)
+	expect := original.Segment(runes)
+	if got := minimized.Segment(runes); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+
+	for i := range runes {
+		if a, b := dict.LongestMatchAt(runes, i), minimized.dict.LongestMatchAt(runes, i); a != b {
+			t.Errorf("Expect match length %d at %d got %d", a, i, b)
+		}
+	}
+}
+
+func BenchmarkMinimizeDefaultDict(b *testing.B) {
+	dict, err := LoadDefaultDict()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var minimized PrefixTree
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		minimized = dict.Minimize()
+	}
+
+	b.ReportMetric(float64(len(dict)), "nodes-before")
+	b.ReportMetric(float64(len(minimized)), "nodes-after")
+}
@@ -0,0 +1,127 @@
+package main
+
+import "sort"
+
+// nbestPath is a partial path of the K-best search, linked back to the
+// path it extends
+type nbestPath struct {
+	WordCount int
+	UnkCount  int
+	S         int
+	prev      *nbestPath
+}
+
+func nbestLess(a, b *nbestPath) bool {
+	if a.UnkCount != b.UnkCount {
+		return a.UnkCount < b.UnkCount
+	}
+	if a.WordCount != b.WordCount {
+		return a.WordCount < b.WordCount
+	}
+	return a.S < b.S
+}
+
+// SegmentNBest returns up to k distinct segmentations of textRunes ordered by
+// score, the fewest unknown words first and then the fewest words. The first
+// result is always the same as Segment.
+func (sm *Segmenter) SegmentNBest(textRunes []rune, k int) [][]string {
+	if k <= 0 {
+		return nil
+	}
+
+	best := sm.Segment(textRunes)
+	length := len(textRunes)
+
+	// incoming[e] maps the start of every candidate edge ending at e to
+	// whether the edge is an unknown word
+	incoming := make([]map[int]int, length+1)
+	for e := 1; e <= length; e++ {
+		s := sm.path[e].S
+		incoming[e] = map[int]int{s: sm.path[e].UnkCount - sm.path[s].UnkCount}
+	}
+
+	for s := 0; s < length; s++ {
+		if !isTextRune(textRunes[s]) {
+			continue
+		}
+		nodeID := 0
+		for j := s; j < length && isTextRune(textRunes[j]); j++ {
+			child, found := sm.dict[PrefixTreeNode{nodeID, j - s, textRunes[j]}]
+			if !found {
+				break
+			}
+			if child.IsFinal {
+				incoming[j+1][s] = 0
+			}
+			nodeID = child.ChildID
+		}
+
+		// an unknown single character is always possible
+		if _, found := incoming[s+1][s]; !found {
+			incoming[s+1][s] = 1
+		}
+	}
+
+	paths := make([][]*nbestPath, length+1)
+	paths[0] = []*nbestPath{{}}
+	for e := 1; e <= length; e++ {
+		var candidates []*nbestPath
+		for s, unk := range incoming[e] {
+			for _, prev := range paths[s] {
+				candidates = append(candidates, &nbestPath{
+					WordCount: prev.WordCount + 1,
+					UnkCount:  prev.UnkCount + unk,
+					S:         s,
+					prev:      prev,
+				})
+			}
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return nbestLess(candidates[i], candidates[j])
+		})
+		// one extra path in case the best segmentation is found again
+		if len(candidates) > k+1 {
+			candidates = candidates[:k+1]
+		}
+		paths[e] = candidates
+	}
+
+	results := [][]string{best}
+	key := joinKey(best)
+	for _, p := range paths[length] {
+		if len(results) == k {
+			break
+		}
+
+		var tokens []string
+		e := length
+		for q := p; q.prev != nil; q = q.prev {
+			tokens = append(tokens, string(textRunes[q.S:e]))
+			e = q.S
+		}
+		for i, j := 0, len(tokens)-1; i < j; i, j = i+1, j-1 {
+			tokens[i], tokens[j] = tokens[j], tokens[i]
+		}
+
+		tokens = sm.postProcess(tokens)
+		if joinKey(tokens) == key {
+			continue
+		}
+		results = append(results, tokens)
+	}
+
+	return results
+}
+
+// isTextRune reports whether ch is handled by dictionary matching
+func isTextRune(ch rune) bool {
+	return !IsLatin(ch) && !IsSpace(ch) && !IsSymbol(ch) && !IsDigit(ch)
+}
+
+func joinKey(tokens []string) string {
+	key := ""
+	for _, token := range tokens {
+		key += token + "\x00"
+	}
+	return key
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSegmentNBest(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป", "ตา", "ตาก", "กลม", "ลม"}))
+	text := []rune("ไปตากลม")
+
+	results := sm.SegmentNBest(text, 4)
+	if len(results) != 4 {
+		t.Fatalf("Expect 4 segmentations got %v", results)
+	}
+
+	if expect := sm.Segment(text); !reflect.DeepEqual(expect, results[0]) {
+		t.Errorf("Expect first result %v got %v", expect, results[0])
+	}
+
+	alternatives := map[string]bool{
+		joinKey([]string{"ไป", "ตา", "กลม"}): true,
+		joinKey([]string{"ไป", "ตาก", "ลม"}): true,
+	}
+	if !alternatives[joinKey(results[0])] || !alternatives[joinKey(results[1])] {
+		t.Errorf("Expect the two dictionary segmentations first got %v", results[:2])
+	}
+
+	seen := make(map[string]bool)
+	for _, result := range results {
+		if seen[joinKey(result)] {
+			t.Errorf("Expect distinct segmentations got %v twice", result)
+		}
+		seen[joinKey(result)] = true
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NGrams joins every n consecutive tokens with a space
+func NGrams(tokens []string, n int) []string {
+	return NGramsWith(tokens, n, " ", false)
+}
+
+// NGramsWith joins every n consecutive tokens with sep, space tokens are
+// dropped before joining when skipSpace is set
+func NGramsWith(tokens []string, n int, sep string, skipSpace bool) []string {
+	if skipSpace {
+		words := make([]string, 0, len(tokens))
+		for _, token := range tokens {
+			if !IsSpaceToken(token) {
+				words = append(words, token)
+			}
+		}
+		tokens = words
+	}
+
+	if n <= 0 || n > len(tokens) {
+		return nil
+	}
+
+	grams := make([]string, 0, len(tokens)-n+1)
+	for i := 0; i+n <= len(tokens); i++ {
+		grams = append(grams, strings.Join(tokens[i:i+n], sep))
+	}
+
+	return grams
+}
+
+// IsSpaceToken reports whether every rune of token is a space
+func IsSpaceToken(token string) bool {
+	if token == "" {
+		return false
+	}
+	for _, ch := range token {
+		if !IsSpace(ch) {
+			return false
+		}
+	}
+	return true
+}
+
+// isSpaceToken reports whether token is a Space run of sm, like
+// IsSpaceToken but with UnicodeSpaces taken into account
+func (sm *Segmenter) isSpaceToken(token string) bool {
+	return sm.isTokenOf(token, sm.isSpace)
+}
+
+// isBlankToken reports whether token is only white space, counting the
+// UnicodeSpaces runes when it is set
+func (sm *Segmenter) isBlankToken(token string) bool {
+	return sm.isTokenOf(token, func(ch rune) bool {
+		return unicode.IsSpace(ch) || (sm.UnicodeSpaces && IsUnicodeSpace(ch))
+	})
+}
+
+// isTokenOf reports whether token is not empty and every rune is space
+func (sm *Segmenter) isTokenOf(token string, space func(rune) bool) bool {
+	if token == "" {
+		return false
+	}
+	for _, ch := range token {
+		if !space(ch) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNGramsBigrams(t *testing.T) {
+	tokens := []string{"ฉัน", "ไป", "กิน", "ข้าว", "เช้า"}
+
+	expect := []string{"ฉัน ไป", "ไป กิน", "กิน ข้าว", "ข้าว เช้า"}
+	if got := NGrams(tokens, 2); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+}
+
+func TestNGramsWithSkipSpace(t *testing.T) {
+	tokens := []string{"ไป", " ", "กิน", "ข้าว"}
+
+	expect := []string{"ไป_กิน", "กิน_ข้าว"}
+	if got := NGramsWith(tokens, 2, "_", true); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import "unicode"
+
+// Normalize lower cases s and composes Thai sara am typed as nikhahit and
+// sara aa, moving a tone mark typed between them before the sara am
+func Normalize(s string) string {
+	runes := []rune(s)
+	out := make([]rune, 0, len(runes))
+	for i := 0; i < len(runes); i++ {
+		ch := runes[i]
+		if ch == 'ํ' {
+			if i+1 < len(runes) && runes[i+1] == 'า' {
+				out = append(out, 'ำ')
+				i++
+				continue
+			}
+			if i+2 < len(runes) && isThaiTone(runes[i+1]) && runes[i+2] == 'า' {
+				out = append(out, runes[i+1], 'ำ')
+				i += 2
+				continue
+			}
+		}
+		out = append(out, unicode.ToLower(ch))
+	}
+
+	return string(out)
+}
+
+// FullWidthToASCII returns the ASCII rune of the full-width form ch in
+// U+FF01 to U+FF5E, other runes are returned as they are
+func FullWidthToASCII(ch rune) rune {
+	if ch >= '\uff01' && ch <= '\uff5e' {
+		return ch - 0xfee0
+	}
+	return ch
+}
+
+// foldFullWidth returns line with FullWidthToASCII applied, line itself when
+// it has no full-width forms
+func (sm *Segmenter) foldFullWidth(line []rune) []rune {
+	for i, ch := range line {
+		if FullWidthToASCII(ch) == ch {
+			continue
+		}
+		sm.wide = append(sm.wide[:0], line...)
+		for j := i; j < len(line); j++ {
+			sm.wide[j] = FullWidthToASCII(line[j])
+		}
+		return sm.wide
+	}
+	return line
+}
+
+// isThaiTone reports whether ch is a Thai tone mark
+func isThaiTone(ch rune) bool {
+	return ch >= '่' && ch <= '๋'
+}
+
+// surfaceForms joins every token with its normalized form by sep
+func surfaceForms(tokens []string, sep string) []string {
+	forms := make([]string, len(tokens))
+	for i, token := range tokens {
+		forms[i] = token + sep + Normalize(token)
+	}
+	return forms
+}
+
+// StripThaiTones removes Thai tone marks from s for loose matching, sara am
+// typed as nikhahit and sara aa is composed
+func StripThaiTones(s string) string {
+	stripped, _ := stripThaiTones([]rune(s))
+	return string(stripped)
+}
+
+// stripThaiTones strips runes like StripThaiTones, index[j] is the offset in
+// runes of stripped[j] and index[len(stripped)] is len(runes)
+func stripThaiTones(runes []rune) (stripped []rune, index []int) {
+	stripped = make([]rune, 0, len(runes))
+	index = make([]int, 0, len(runes)+1)
+	for i := 0; i < len(runes); i++ {
+		ch := runes[i]
+		if isThaiTone(ch) {
+			continue
+		}
+
+		start := i
+		if ch == 'ํ' {
+			j := i + 1
+			for j < len(runes) && isThaiTone(runes[j]) {
+				j++
+			}
+			if j < len(runes) && runes[j] == 'า' {
+				ch = 'ำ'
+				i = j
+			}
+		}
+		stripped = append(stripped, ch)
+		index = append(index, start)
+	}
+	index = append(index, len(runes))
+
+	return stripped, index
+}
+
+// Loose returns a prefix tree of the words of t with StripThaiTones applied,
+// for a Segmenter with LooseMatch
+func (t PrefixTree) Loose() PrefixTree {
+	words := t.Words()
+	for i, word := range words {
+		words[i] = StripThaiTones(word)
+	}
+	return MakeDict(words)
+}
+
+// eachLooseSpan builds the path of sentence with tone marks stripped and
+// yields its tokens, which keep the original text and tone marks. sentence
+// starts at offset base of the text.
+func (sm *Segmenter) eachLooseSpan(sentence []rune, base int, yield func(s, e, at int)) {
+	stripped, index := stripThaiTones(sentence)
+	sm.BuildPath(stripped)
+	for _, e := range sm.pathEnds(len(stripped)) {
+		yield(base+index[sm.path[e].S], base+index[e], e)
+	}
+}
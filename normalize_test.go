@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in, expect string
+	}{
+		{"iPhone", "iphone"},
+		{"นํ้า", "น้ำ"},
+		{"ทํา", "ทำ"},
+		{"ข้าว", "ข้าว"},
+	}
+	for _, test := range tests {
+		if got := Normalize(test.in); got != test.expect {
+			t.Errorf("Expect %q got %q", test.expect, got)
+		}
+	}
+}
+
+func TestWorkerSurfaceSep(t *testing.T) {
+	w := NewSegmenterWorkerFromWords([]string{"ซื้อ"})
+	w.SurfaceSep = "/"
+
+	var out bytes.Buffer
+	if err := w.RunIO(strings.NewReader("ซื้อiPhone\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	if expect := "ซื้อ/ซื้อ|iPhone/iphone\n"; out.String() != expect {
+		t.Errorf("Expect %q got %q", expect, out.String())
+	}
+}
+
+func TestStripThaiTones(t *testing.T) {
+	if got := StripThaiTones("นํ้า"); got != StripThaiTones("น้ำ") || got != "นำ" {
+		t.Errorf("Expect both forms stripped to %q got %q", "นำ", got)
+	}
+}
+
+func TestSegmentLooseMatch(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ดื่ม", "น้ำ", "เย็น"}).Loose())
+	sm.LooseMatch = true
+
+	expect := []string{"ดืม", "นํ้า", "เย็น"}
+	if got := sm.Segment([]rune("ดืมนํ้าเย็น")); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+
+	// Boundary runes are dropped with LooseMatch too
+	sm.Boundary = '|'
+	expect = []string{"ดืม", "นํ้า", "เย็น"}
+	if got := sm.Segment([]rune("ดืม|นํ้า|เย็น")); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+
+	w := &SegmenterWorker{
+		dict:       MakePrefixTree([]string{"ไป", "กิน", "ข้าว"}),
+		KeepDelim:  true,
+		LooseMatch: true,
+	}
+	if got, expect := w.SegmentLine(w.newSegmenter(), []rune("ไป|กิน|ข้าว")), "ไป|กิน|ข้าว"; got != expect {
+		t.Errorf("Expect %q got %q", expect, got)
+	}
+}
+
+func TestSegmentFoldFullWidth(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ซื้อ", "ไอโฟน15"}))
+	text := []rune("ซื้อＡｐｐｌｅ１２ไอโฟน１５")
+
+	expect := []string{"ซื้อ", "Ａｐｐｌｅ１２ไอโฟน１５"}
+	if got := sm.Segment(text); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+
+	sm.FoldFullWidth = true
+
+	expect = []string{"ซื้อ", "Ａｐｐｌｅ", "１２", "ไอโฟน", "１５"}
+	if got := sm.Segment(text); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+	if got := TokenType(string(sm.foldFullWidth([]rune("Ａｐｐｌｅ")))); got != Latin {
+		t.Errorf("Expect Ａｐｐｌｅ to be classified as Latin got %v", got)
+	}
+
+	sm.MixedWords = true
+
+	expect = []string{"ซื้อ", "Ａｐｐｌｅ", "１２", "ไอโฟน１５"}
+	if got := sm.Segment(text); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+}
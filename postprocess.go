@@ -0,0 +1,195 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// RepeatMark is the Thai repetition mark mai yamok
+const RepeatMark = "ๆ"
+
+// RepeatMode is how Segment emits the repetition mark
+type RepeatMode int
+
+const (
+	// RepeatKeep emits the mark as its own token
+	RepeatKeep RepeatMode = iota
+	// RepeatAttach attaches the mark to the preceding token
+	RepeatAttach
+	// RepeatExpand replaces the mark with a copy of the preceding token
+	RepeatExpand
+)
+
+// IsLossless reports whether the tokens of textRunes join back to the
+// original text. Output filters such as Stopwords, Boundary and RepeatExpand
+// change the text, space folding of quotes and parentheses does not.
+func (sm *Segmenter) IsLossless(textRunes []rune) bool {
+	return strings.Join(sm.Segment(textRunes), "") == string(textRunes)
+}
+
+// postProcess applies the configured output filters to tokens
+func (sm *Segmenter) postProcess(tokens []string) []string {
+	if sm.Repeat != RepeatKeep {
+		tokens = sm.handleRepeat(tokens)
+	}
+
+	if sm.MergeSingleRunes {
+		tokens = sm.mergeSingleRunes(tokens)
+	}
+
+	if sm.SplitCamelCase {
+		tokens = splitCamelCase(tokens)
+	}
+
+	if sm.Stopwords != nil {
+		n := 0
+		for _, token := range tokens {
+			if _, found := sm.Stopwords[token]; !found {
+				tokens[n] = token
+				n++
+			}
+		}
+		tokens = tokens[:n]
+	}
+
+	if sm.LatinFold {
+		for i, token := range tokens {
+			if TokenType(token) == Latin {
+				tokens[i] = strings.ToLower(token)
+			}
+		}
+	}
+
+	if sm.MaxTokenRunes > 0 {
+		tokens = splitLongTokens(tokens, sm.MaxTokenRunes)
+	}
+
+	return tokens
+}
+
+// splitLongTokens splits tokens longer than max runes into pieces of max
+// runes, the last piece of a token may be shorter
+func splitLongTokens(tokens []string, max int) []string {
+	var split []string
+	for i, token := range tokens {
+		runes := []rune(token)
+		if len(runes) <= max {
+			if split != nil {
+				split = append(split, token)
+			}
+			continue
+		}
+
+		if split == nil {
+			split = append(make([]string, 0, len(tokens)+1), tokens[:i]...)
+		}
+		for len(runes) > max {
+			split = append(split, string(runes[:max]))
+			runes = runes[max:]
+		}
+		split = append(split, string(runes))
+	}
+
+	if split == nil {
+		return tokens
+	}
+	return split
+}
+
+// splitCamelCase splits Latin tokens before every upper case letter
+// following a lower case letter
+func splitCamelCase(tokens []string) []string {
+	var split []string
+	for i, token := range tokens {
+		var cuts []int
+		if TokenType(token) == Latin {
+			runes := []rune(token)
+			for j := 1; j < len(runes); j++ {
+				if unicode.IsLower(runes[j-1]) && unicode.IsUpper(runes[j]) {
+					cuts = append(cuts, j)
+				}
+			}
+			if len(cuts) > 0 {
+				if split == nil {
+					split = append(make([]string, 0, len(tokens)+len(cuts)), tokens[:i]...)
+				}
+				start := 0
+				for _, cut := range cuts {
+					split = append(split, string(runes[start:cut]))
+					start = cut
+				}
+				split = append(split, string(runes[start:]))
+				continue
+			}
+		}
+		if split != nil {
+			split = append(split, token)
+		}
+	}
+
+	if split == nil {
+		return tokens
+	}
+	return split
+}
+
+// mergeSingleRunes replaces every longest run of single rune text tokens
+// forming a dictionary word with that word
+func (sm *Segmenter) mergeSingleRunes(tokens []string) []string {
+	single := func(token string) bool {
+		return utf8.RuneCountInString(token) == 1 && sm.tokenType(token) == Text
+	}
+
+	n := 0
+	for i := 0; i < len(tokens); {
+		end := i
+		for end < len(tokens) && single(tokens[end]) {
+			end++
+		}
+
+		merged := 1
+		for j := end; j > i+1; j-- {
+			if sm.isWord(strings.Join(tokens[i:j], "")) {
+				merged = j - i
+				break
+			}
+		}
+
+		tokens[n] = strings.Join(tokens[i:i+merged], "")
+		n++
+		i += merged
+	}
+
+	return tokens[:n]
+}
+
+// isWord reports whether word is a word of the dictionary
+func (sm *Segmenter) isWord(word string) bool {
+	runes := []rune(word)
+	if sm.chain != nil {
+		return sm.chain.LongestMatchAt(runes, 0) == len(runes)
+	}
+	return sm.dict.LongestMatchAt(runes, 0) == len(runes)
+}
+
+func (sm *Segmenter) handleRepeat(tokens []string) []string {
+	n := 0
+	for _, token := range tokens {
+		if token != RepeatMark || n == 0 || sm.isSpaceToken(tokens[n-1]) {
+			tokens[n] = token
+			n++
+			continue
+		}
+
+		switch sm.Repeat {
+		case RepeatAttach:
+			tokens[n-1] += token
+		case RepeatExpand:
+			tokens[n] = tokens[n-1]
+			n++
+		}
+	}
+
+	return tokens[:n]
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSegmentRepeatMark(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"เด็ก", "เล่น"}))
+	text := []rune("เด็กๆเล่น")
+
+	expect := []string{"เด็ก", "ๆ", "เล่น"}
+	if got := sm.Segment(text); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+
+	sm.Repeat = RepeatAttach
+	expect = []string{"เด็กๆ", "เล่น"}
+	if got := sm.Segment(text); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+
+	sm.Repeat = RepeatExpand
+	expect = []string{"เด็ก", "เด็ก", "เล่น"}
+	if got := sm.Segment(text); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+
+	// the mark is not grouped with the unknown text around it
+	sm.Repeat = RepeatKeep
+	expect = []string{"เด็ก", "ๆ", "ฮฮ"}
+	if got := sm.Segment([]rune("เด็กๆฮฮ")); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+	expect = []string{"ฮฮ", "ๆ", "เล่น"}
+	if got := sm.Segment([]rune("ฮฮๆเล่น")); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+
+	sm.Repeat = RepeatAttach
+	expect = []string{"เด็กๆ", "ฮฮ"}
+	if got := sm.Segment([]rune("เด็กๆฮฮ")); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+}
+
+func TestIsLossless(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"เด็ก", "เล่น"}))
+	text := []rune(`"เด็กๆ" (เล่น)`)
+
+	if !sm.IsLossless(text) {
+		t.Errorf("Expect quotes and parentheses to be kept got %q", sm.Segment(text))
+	}
+
+	sm.Repeat = RepeatExpand
+	if sm.IsLossless(text) {
+		t.Errorf("Expect expanded repetition to change the text got %q", sm.Segment(text))
+	}
+
+	sm.Repeat = RepeatKeep
+	sm.Stopwords = map[string]struct{}{"เล่น": {}}
+	if sm.IsLossless(text) {
+		t.Errorf("Expect removed stopwords to change the text got %q", sm.Segment(text))
+	}
+}
+
+func TestSegmentMaxTokenRunes(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป", "กรุงเทพมหานคร"}))
+	sm.MaxTokenRunes = 4
+
+	expect := []string{"ไป", "กรุง", "เทพม", "หานค", "ร"}
+	if got := sm.Segment([]rune("ไปกรุงเทพมหานคร")); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+}
+
+func TestSegmentLatinFold(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ซื้อ", "ใหม่"}))
+	sm.LatinFold = true
+
+	expect := []string{"ซื้อ", "iphone", " ", "ใหม่", "abc", "中"}
+	if got := sm.Segment([]rune("ซื้อiPhone ใหม่ABc中")); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+}
+
+func TestSegmentSplitCamelCase(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ซื้อ", "ใหม่"}))
+
+	expect := []string{"ซื้อ", "iPhoneCase", " ", "ใหม่", "HTTPServer"}
+	if got := sm.Segment([]rune("ซื้อiPhoneCase ใหม่HTTPServer")); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+
+	sm.SplitCamelCase = true
+
+	expect = []string{"ซื้อ", "i", "Phone", "Case", " ", "ใหม่", "HTTPServer"}
+	if got := sm.Segment([]rune("ซื้อiPhoneCase ใหม่HTTPServer")); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+}
+
+func TestSegmentMergeSingleRunes(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป", "ห", "ก", "หก", "ล้ม"}))
+	sm.Comparator = mostWords{}
+	text := []rune("ไปหกล้ม ก ห")
+
+	expect := []string{"ไป", "ห", "ก", "ล้ม", " ", "ก", " ", "ห"}
+	if got := sm.Segment(text); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+
+	sm.MergeSingleRunes = true
+
+	expect = []string{"ไป", "หก", "ล้ม", " ", "ก", " ", "ห"}
+	if got := sm.Segment(text); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// TokenRecord is a token written in the TokenRecords output, Start is -1
+// for a token not found in the line
+type TokenRecord struct {
+	Line  int    `json:"line"`
+	Index int    `json:"index"`
+	Start int    `json:"start"`
+	Token string `json:"token"`
+}
+
+// tokenRecords segments a line of input into JSON line records of the
+// output tokens of the worker
+func (w *SegmenterWorker) tokenRecords(sm *Segmenter, lineInput LineInput) string {
+	tokens, offsets := w.segmentTokenOffsets(sm, lineInput.textRunes, true)
+
+	var out strings.Builder
+	for i, token := range tokens {
+		b, _ := json.Marshal(TokenRecord{
+			Line:  lineInput.lineNo,
+			Index: i,
+			Start: offsets[i],
+			Token: token,
+		})
+		out.Write(b)
+		out.WriteByte('\n')
+	}
+
+	return out.String()
+}
+
+// tokenOffsets returns the rune offset of every token in text. Tokens are
+// looked up in order after the end of the previous token found, the offset
+// is -1 for a token not found such as an expanded repetition.
+func tokenOffsets(text []rune, tokens []string) []int {
+	offsets := make([]int, len(tokens))
+	at := 0
+	for i, token := range tokens {
+		offsets[i] = -1
+		for j := at; j < len(text); j++ {
+			if n, ok := runePrefixLen(text[j:], token); ok {
+				offsets[i] = j
+				at = j + n
+				break
+			}
+		}
+	}
+	return offsets
+}
+
+// runePrefixLen reports whether runes begins with the runes of token and
+// how many they are
+func runePrefixLen(runes []rune, token string) (int, bool) {
+	n := 0
+	for _, ch := range token {
+		if n >= len(runes) || runes[n] != ch {
+			return 0, false
+		}
+		n++
+	}
+	return n, n > 0
+}
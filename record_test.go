@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestTokenRecords(t *testing.T) {
+	w := &SegmenterWorker{
+		dict:         MakePrefixTree([]string{"ไป", "กิน", "ข้าว"}),
+		TokenRecords: true,
+	}
+
+	lines := []string{"ไปกินข้าว", "", "กิน ข้าว"}
+	var out bytes.Buffer
+	if err := w.RunIO(strings.NewReader(strings.Join(lines, "\n")+"\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	rebuilt := make([]string, len(lines))
+	last := TokenRecord{Line: -1}
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var record TokenRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatal(err)
+		}
+
+		if record.Line < last.Line || (record.Line == last.Line && record.Index != last.Index+1) {
+			t.Errorf("Expect records in input order got %v after %v", record, last)
+		}
+		if start := len([]rune(rebuilt[record.Line])); record.Start != start {
+			t.Errorf("Expect %q to start at %d got %d", record.Token, start, record.Start)
+		}
+		rebuilt[record.Line] += record.Token
+		last = record
+	}
+
+	for i, line := range lines {
+		if rebuilt[i] != line {
+			t.Errorf("Expect line %d to be %q got %q", i, line, rebuilt[i])
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Coverage segments every line read from r and counts the characters of
+// known and unknown tokens. Space tokens are not counted.
+func (sm *Segmenter) Coverage(r io.Reader) (knownChars, unknownChars int, err error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		textRunes := []rune(scanner.Text())
+		sm.eachSpan(textRunes, func(s, e, at int) {
+			switch {
+			case sm.isSpaceToken(string(textRunes[s:e])):
+			case at >= 0 && sm.path[at].UnkCount > sm.path[sm.path[at].S].UnkCount:
+				unknownChars += e - s
+			default:
+				knownChars += e - s
+			}
+		})
+	}
+
+	return knownChars, unknownChars, scanner.Err()
+}
+
+// LengthStats are the rune lengths of segmented tokens
+type LengthStats struct {
+	Count  int
+	Min    int
+	Max    int
+	Mean   float64
+	Median float64
+}
+
+// SegmentStats segments every line read from r and returns the length
+// statistics of the tokens, space tokens are left out when skipSpace is set
+func (sm *Segmenter) SegmentStats(r io.Reader, skipSpace bool) (LengthStats, error) {
+	var lengths []int
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		for _, token := range sm.Segment([]rune(scanner.Text())) {
+			if skipSpace && sm.isSpaceToken(token) {
+				continue
+			}
+			lengths = append(lengths, utf8.RuneCountInString(token))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return LengthStats{}, err
+	}
+
+	stats := LengthStats{Count: len(lengths)}
+	if len(lengths) == 0 {
+		return stats, nil
+	}
+
+	sort.Ints(lengths)
+	sum := 0
+	for _, n := range lengths {
+		sum += n
+	}
+	stats.Min = lengths[0]
+	stats.Max = lengths[len(lengths)-1]
+	stats.Mean = float64(sum) / float64(len(lengths))
+
+	mid := len(lengths) / 2
+	if len(lengths)%2 == 0 {
+		stats.Median = float64(lengths[mid-1]+lengths[mid]) / 2
+	} else {
+		stats.Median = float64(lengths[mid])
+	}
+
+	return stats, nil
+}
+
+// UnknownCounts are numbers of unknown words by the script they begin with
+type UnknownCounts struct {
+	Thai  int
+	Latin int
+	Other int
+}
+
+// UnknownByScript segments every line read from r and counts the words not
+// matched in the dictionary by script. Latin runs not in the dictionary are
+// counted as unknown too, space, number and symbol runs are not counted.
+func (sm *Segmenter) UnknownByScript(r io.Reader) (UnknownCounts, error) {
+	var counts UnknownCounts
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		textRunes := []rune(scanner.Text())
+		sm.eachSpan(textRunes, func(s, e, at int) {
+			if at < 0 || sm.nodes[at] != UnknownNodeID {
+				return
+			}
+			switch sm.tokenType(string(textRunes[s:e])) {
+			case Latin:
+				counts.Latin++
+			case Text:
+				if unicode.Is(unicode.Thai, textRunes[s]) {
+					counts.Thai++
+				} else {
+					counts.Other++
+				}
+			}
+		})
+	}
+
+	return counts, scanner.Err()
+}
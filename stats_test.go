@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCoverage(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป", "กิน"}))
+
+	known, unknown, err := sm.Coverage(strings.NewReader("ไป กินข้าว\nกิน\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if known != 8 || unknown != 4 {
+		t.Errorf("Expect 8 known and 4 unknown characters got %d and %d", known, unknown)
+	}
+}
+
+func TestSegmentStats(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป", "กิน", "ข้าว"}))
+	corpus := "ไป กินข้าว\nกิน\n"
+
+	stats, err := sm.SegmentStats(strings.NewReader(corpus), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := (LengthStats{Count: 4, Min: 2, Max: 4, Mean: 3, Median: 3}); stats != expect {
+		t.Errorf("Expect %+v got %+v", expect, stats)
+	}
+
+	stats, err = sm.SegmentStats(strings.NewReader(corpus), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := (LengthStats{Count: 5, Min: 1, Max: 4, Mean: 2.6, Median: 3}); stats != expect {
+		t.Errorf("Expect %+v got %+v", expect, stats)
+	}
+}
+
+func TestUnknownByScript(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป", "กิน", "ข้าว", "ok"}))
+	sm.FoldLatinMatch = true
+
+	counts, err := sm.UnknownByScript(strings.NewReader("ไปฮฮ abc กินข้าว OK 12\n中文ไป xyz\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expect := (UnknownCounts{Thai: 1, Latin: 2, Other: 1}); counts != expect {
+		t.Errorf("Expect %+v got %+v", expect, counts)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// segmentReader segments the lines of src as they are read
+type segmentReader struct {
+	sm    *Segmenter
+	src   *bufio.Reader
+	delim string
+	buf   []byte
+	err   error
+	began bool
+}
+
+// NewSegmentReader returns a reader of the lines of src segmented with dict,
+// tokens are joined with delim
+func NewSegmentReader(dict PrefixTree, src io.Reader, delim string) io.Reader {
+	return &segmentReader{
+		sm:    NewSegmenter(dict),
+		src:   bufio.NewReader(src),
+		delim: delim,
+	}
+}
+
+func (r *segmentReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		var line string
+		line, r.err = r.src.ReadString('\n')
+		if line == "" {
+			continue
+		}
+
+		if !r.began {
+			line = strings.TrimPrefix(line, string(bom))
+			r.began = true
+		}
+
+		text := strings.TrimSuffix(line, "\n")
+		out := strings.Join(r.sm.Segment([]rune(text)), r.delim)
+		if len(text) != len(line) {
+			out += "\n"
+		}
+		r.buf = append(r.buf[:0], out...)
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// streamBufferRunes is how many runes SegmentStreamRunes holds back at most
+// waiting for a certain boundary
+const streamBufferRunes = 256
+
+// SegmentStreamRunes segments the runes received from in and sends every
+// token as soon as it is certain, that is when no dictionary word, run,
+// number, unknown word or output filter can span the boundary after it.
+// The tokens sent are the tokens of Segment on all runes, except that
+// streamBufferRunes runes without a certain boundary are cut at their last
+// token boundary, or all of them when there is none. The channel is closed
+// after in is closed and the remaining runes are segmented. sm must not be
+// used until then.
+func (sm *Segmenter) SegmentStreamRunes(in <-chan rune) <-chan string {
+	out := make(chan string)
+
+	go func() {
+		defer close(out)
+
+		var buf []rune
+		for ch := range in {
+			buf = append(buf, ch)
+			p, head := sm.commitPoint(buf)
+			if p == 0 && len(buf) >= streamBufferRunes {
+				p, head = sm.cutPoint(buf)
+			}
+			if p == 0 {
+				continue
+			}
+			for _, token := range head {
+				out <- token
+			}
+			buf = append(buf[:0], buf[p:]...)
+		}
+
+		for _, token := range sm.Segment(buf) {
+			out <- token
+		}
+	}()
+
+	return out
+}
+
+// commitPoint returns the last offset p of buf the segmentation of buf is
+// certain to have a boundary at however buf continues, with the tokens of
+// buf[:p], or 0. Segmenting buf[:p] and buf[p:] separately must give the
+// tokens of buf, and no part of the boundary conditions may depend on runes
+// after buf.
+func (sm *Segmenter) commitPoint(buf []rune) (int, []string) {
+	// unknown words of the first dictionary of a chain may still grow, so
+	// its path decides which offsets are inside one
+	fallback := sm.fallback
+	sm.fallback = nil
+	sm.BuildPath(buf)
+	sm.fallback = fallback
+
+	oov := append([]bool(nil), sm.oov...)
+	starts := make([]int, len(buf)+1)
+	for e := range starts {
+		starts[e] = sm.path[e].S
+	}
+	// only the token boundaries of the path can split buf without a change
+	boundary := make([]bool, len(buf)+1)
+	for e := len(buf); e > 0; e = starts[e] {
+		boundary[e] = true
+	}
+	types := make([]WordType, len(buf))
+	current := Unknow
+	for i := range buf {
+		types[i] = sm.runType(buf, i, current)
+		current = types[i]
+	}
+
+	var full []string
+	for p := len(buf) - 1; p > 0; p-- {
+		if !boundary[p] || oov[p] {
+			continue
+		}
+		if types[p-1] != Text && types[p-1] == types[p] {
+			continue
+		}
+		if sm.contextual(buf, p) || sm.prefixCrosses(buf, p) || sm.filterCrosses(buf, p, starts[p]) {
+			continue
+		}
+
+		if full == nil {
+			full = sm.Segment(buf)
+		}
+		head := sm.Segment(buf[:p])
+		tail := sm.Segment(buf[p:])
+		if reflect.DeepEqual(full, append(append([]string(nil), head...), tail...)) {
+			return p, head
+		}
+	}
+	return 0, nil
+}
+
+// cutPoint returns the last token boundary p of buf other than its start,
+// len(buf) when there is none, with the tokens of buf[:p]
+func (sm *Segmenter) cutPoint(buf []rune) (int, []string) {
+	bounds := sm.Boundaries(buf)
+	p := len(buf)
+	if len(bounds) > 2 {
+		p = bounds[len(bounds)-2]
+	}
+	return p, sm.Segment(buf[:p])
+}
+
+// contextual reports whether the run type of a rune from buf[p] on depends
+// on runes after buf, like the . of a number or a joiner at the end of buf
+func (sm *Segmenter) contextual(buf []rune, p int) bool {
+	j := p
+	for j < len(buf) && (buf[j] == '.' || buf[j] == ',' || strings.ContainsRune(sm.Joiners, buf[j])) {
+		j++
+	}
+	return j == len(buf)
+}
+
+// filterCrosses reports whether an output filter of Segment may join the
+// tokens before and after p, a repetition mark, single rune merging or a
+// sentence separator. s is the start of the token before p.
+func (sm *Segmenter) filterCrosses(buf []rune, p, s int) bool {
+	if sm.Repeat != RepeatKeep && buf[p] == []rune(RepeatMark)[0] {
+		return true
+	}
+	if sm.MergeSingleRunes && s == p-1 {
+		return true
+	}
+
+	sep := []rune(sm.SentenceSep)
+	for k := 1; k < len(sep); k++ {
+		if p < k || string(buf[p-k:p]) != string(sep[:k]) {
+			continue
+		}
+		rest := buf[p:]
+		if len(rest) > len(sep)-k {
+			rest = rest[:len(sep)-k]
+		}
+		if string(rest) == string(sep[k:k+len(rest)]) {
+			return true
+		}
+	}
+	return false
+}
+
+// prefixCrosses reports whether a dictionary prefix starting before p
+// continues over buf[p]
+func (sm *Segmenter) prefixCrosses(buf []rune, p int) bool {
+	dicts := sm.chain
+	if dicts == nil {
+		dicts = DictChain{sm.dict}
+	}
+	if sm.FoldFullWidth {
+		buf = sm.foldFullWidth(buf)
+	}
+
+	for _, dict := range dicts {
+		for s := p - 1; s >= 0; s-- {
+			nodeID, offset := 0, 0
+			j := s
+			for ; j <= p; j++ {
+				if sm.LooseMatch && isThaiTone(buf[j]) {
+					continue
+				}
+				child, found := dict[PrefixTreeNode{nodeID, offset, buf[j]}]
+				if !found {
+					break
+				}
+				nodeID = child.ChildID
+				offset++
+			}
+			if j > p {
+				return true
+			}
+		}
+	}
+	return false
+}
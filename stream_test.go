@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSegmentReader(t *testing.T) {
+	dict := MakePrefixTree([]string{"ไป", "กิน", "ข้าว"})
+	r := NewSegmentReader(dict, strings.NewReader("ไปกินข้าว\n\nกินข้าว"), "|")
+
+	var out bytes.Buffer
+	if _, err := io.Copy(&out, r); err != nil {
+		t.Fatal(err)
+	}
+
+	if expect := "ไป|กิน|ข้าว\n\nกิน|ข้าว"; out.String() != expect {
+		t.Errorf("Expect %q got %q", expect, out.String())
+	}
+}
+
+// streamTokens segments text with SegmentStreamRunes
+func streamTokens(sm *Segmenter, text string) []string {
+	in := make(chan rune)
+	out := sm.SegmentStreamRunes(in)
+
+	var got []string
+	done := make(chan struct{})
+	go func() {
+		for token := range out {
+			got = append(got, token)
+		}
+		close(done)
+	}()
+
+	for _, ch := range text {
+		in <- ch
+	}
+	close(in)
+	<-done
+	return got
+}
+
+func TestSegmentStreamRunes(t *testing.T) {
+	dict := MakePrefixTree([]string{"ไป", "กิน", "ข้าว", "กินข้าว", "ที่", "บ้าน"})
+	sm := NewSegmenter(dict)
+
+	for _, text := range []string{
+		"ไปกินข้าวที่บ้าน abc ฮฮไป",
+		"a1.1ลต",
+		"ราคา 1,000.50 บาท 2.",
+	} {
+		expect := NewSegmenter(dict).Segment([]rune(text))
+		if got := streamTokens(sm, text); !reflect.DeepEqual(expect, got) {
+			t.Errorf("Expect %q got %q", expect, got)
+		}
+	}
+}
+
+func TestSegmentStreamRunesMatchesSegment(t *testing.T) {
+	dict := MakePrefixTree([]string{"ไป", "กิน", "ข้าว", "กินข้าว", "ลม", "ตา", "ตาก", "กลม"})
+	alphabet := []rune("ไปกินข้าวลมตากฮ ab1.,ๆ<s>_")
+	options := []func(*Segmenter){
+		func(sm *Segmenter) {},
+		func(sm *Segmenter) { sm.Repeat = RepeatAttach },
+		func(sm *Segmenter) { sm.SentenceSep = "<s>" },
+		func(sm *Segmenter) { sm.Joiners = "_." },
+		func(sm *Segmenter) { sm.MixedWords = true },
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for n := 0; n < 3000; n++ {
+		runes := make([]rune, 1+rng.Intn(12))
+		for i := range runes {
+			runes[i] = alphabet[rng.Intn(len(alphabet))]
+		}
+		text := string(runes)
+
+		option := options[n%len(options)]
+		sm, stream := NewSegmenter(dict), NewSegmenter(dict)
+		option(sm)
+		option(stream)
+
+		expect := sm.Segment(runes)
+		if got := streamTokens(stream, text); !reflect.DeepEqual(expect, got) {
+			t.Fatalf("Expect %q for %q with option %d got %q", expect, text, n%len(options), got)
+		}
+	}
+}
+
+func TestSegmentStreamRunesCommitsEarly(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป", "กิน", "กินข้าว"}))
+
+	in := make(chan rune, 16)
+	out := sm.SegmentStreamRunes(in)
+
+	for _, ch := range "ไปกิน " {
+		in <- ch
+	}
+	for _, expect := range []string{"ไป", "กิน"} {
+		if got := <-out; got != expect {
+			t.Errorf("Expect %q got %q", expect, got)
+		}
+	}
+
+	close(in)
+	if got := <-out; got != " " {
+		t.Errorf("Expect the trailing space got %q", got)
+	}
+	if _, ok := <-out; ok {
+		t.Error("Expect the channel closed")
+	}
+}
+
+func TestSegmentStreamRunesLongUnknown(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป", "กิน"}))
+	text := strings.Repeat("ฮ", 20*streamBufferRunes) + "ไปกิน"
+
+	start := time.Now()
+	got := streamTokens(sm, text)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expect the held back runes to be capped, took %v", elapsed)
+	}
+
+	if joined := strings.Join(got, ""); joined != text {
+		t.Errorf("Expect the tokens to join to the text got %d runes", len([]rune(joined)))
+	}
+	for _, token := range got {
+		if n := len([]rune(token)); n > streamBufferRunes {
+			t.Errorf("Expect tokens of at most %d runes got %d", streamBufferRunes, n)
+		}
+	}
+	if tail := got[len(got)-2:]; !reflect.DeepEqual([]string{"ไป", "กิน"}, tail) {
+		t.Errorf("Expect [ไป กิน] at the end got %v", tail)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Token is a segmented token with its type
+type Token struct {
+	Text string
+	Type WordType
+
+	// Value is the parsed value of Number tokens
+	Value *NumberValue
+}
+
+// NumberValue is the value of a Number token, Int is set when the number
+// has no decimal point
+type NumberValue struct {
+	Int     int64
+	Float   float64
+	IsFloat bool
+}
+
+// SegmentTokens segments textRunes like Segment and returns typed tokens
+func (sm *Segmenter) SegmentTokens(textRunes []rune) []Token {
+	words := sm.Segment(textRunes)
+	tokens := make([]Token, len(words))
+	for i, word := range words {
+		tokens[i] = Token{
+			Text: word,
+			Type: sm.tokenType(word),
+		}
+		if tokens[i].Type == Number {
+			tokens[i].Value = ParseNumber(word)
+		}
+	}
+
+	return tokens
+}
+
+// SegmentSentenceEnds segments textRunes like Segment and also reports for
+// each token whether it may end a sentence, that is it is followed by a
+// whitespace or sentence punctuation token or ends the text
+func (sm *Segmenter) SegmentSentenceEnds(textRunes []rune) ([]string, []bool) {
+	tokens := sm.Segment(textRunes)
+	ends := make([]bool, len(tokens))
+
+	last := true
+	for i := len(tokens) - 1; i >= 0; i-- {
+		if sm.isSentenceBreak(tokens[i]) {
+			last = true
+			continue
+		}
+		ends[i] = last
+		last = false
+	}
+
+	return tokens, ends
+}
+
+// isSentenceBreak reports whether token is whitespace or sentence
+// punctuation
+func (sm *Segmenter) isSentenceBreak(token string) bool {
+	if token == "" || sm.isBlankToken(token) {
+		return true
+	}
+	return strings.Trim(token, ".!?ฯ…") == ""
+}
+
+// SplitSentences splits text into sentences to segment one by one. It is a
+// heuristic: a sentence ends at a newline, at a space between two Thai
+// letters and after . ! ? or … followed by a space. Only Unicode white
+// space counts as space, quotes and parentheses do not. Sentences are trimmed
+// of whitespace, empty ones are dropped.
+func SplitSentences(text string) []string {
+	var sentences []string
+	runes := []rune(text)
+	start := 0
+	add := func(end int) {
+		if sentence := strings.TrimSpace(string(runes[start:end])); sentence != "" {
+			sentences = append(sentences, sentence)
+		}
+		start = end
+	}
+
+	for i := 0; i < len(runes); i++ {
+		switch ch := runes[i]; {
+		case ch == '\n' || ch == '\r':
+			add(i)
+		case ch == '.' || ch == '!' || ch == '?' || ch == '…':
+			if i+1 < len(runes) && unicode.IsSpace(runes[i+1]) {
+				add(i + 1)
+			}
+		case unicode.IsSpace(ch):
+			end := i
+			for end < len(runes) && unicode.IsSpace(runes[end]) && runes[end] != '\n' && runes[end] != '\r' {
+				end++
+			}
+			if i > 0 && end < len(runes) && isThaiLetter(runes[i-1]) && isThaiLetter(runes[end]) {
+				add(i)
+			}
+			i = end - 1
+		}
+	}
+	add(len(runes))
+
+	return sentences
+}
+
+// isThaiLetter reports whether ch is a Thai rune other than a digit
+func isThaiLetter(ch rune) bool {
+	return unicode.Is(unicode.Thai, ch) && !IsDigit(ch)
+}
+
+// TokenType returns the word type of a token by its first character
+func TokenType(token string) WordType {
+	for _, ch := range token {
+		switch {
+		case IsLatin(ch):
+			return Latin
+		case IsSpace(ch):
+			return Space
+		case IsSymbol(ch):
+			return Symbol
+		case IsDigit(ch):
+			return Number
+		}
+		return Text
+	}
+	return Unknow
+}
+
+// tokenType returns the word type of a token like TokenType, a token of
+// the UnicodeSpaces runes is Space when it is set
+func (sm *Segmenter) tokenType(token string) WordType {
+	wordType := TokenType(token)
+	if ch, _ := utf8.DecodeRuneInString(token); wordType == Text && sm.isSpace(ch) {
+		return Space
+	}
+	return wordType
+}
+
+// ParseNumber parses a number written in Arabic or Thai digits, it returns
+// nil when s is not a number
+func ParseNumber(s string) *NumberValue {
+	s = strings.Map(func(ch rune) rune {
+		switch {
+		case ch >= '๐' && ch <= '๙':
+			return '0' + (ch - '๐')
+		case ch == ',':
+			return -1
+		}
+		return ch
+	}, s)
+
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return &NumberValue{Int: i, Float: float64(i)}
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return &NumberValue{Float: f, IsFloat: true}
+	}
+	return nil
+}
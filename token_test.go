@@ -0,0 +1,55 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSegmentTokensNumber(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ปี", "ราคา", "บาท"}))
+
+	tokens := sm.SegmentTokens([]rune("ปี๒๕๖๓ราคา1,250.50บาท"))
+
+	expect := []Token{
+		{Text: "ปี", Type: Text},
+		{Text: "๒๕๖๓", Type: Number, Value: &NumberValue{Int: 2563, Float: 2563}},
+		{Text: "ราคา", Type: Text},
+		{Text: "1,250.50", Type: Number, Value: &NumberValue{Float: 1250.5, IsFloat: true}},
+		{Text: "บาท", Type: Text},
+	}
+	if !reflect.DeepEqual(expect, tokens) {
+		t.Errorf("Expect %v got %v", expect, tokens)
+	}
+}
+
+func TestSegmentSentenceEnds(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ฉัน", "กิน", "ข้าว", "เขา", "นอน"}))
+
+	tokens, ends := sm.SegmentSentenceEnds([]rune("ฉันกินข้าว เขานอน!"))
+	if expect := []string{"ฉัน", "กิน", "ข้าว", " ", "เขา", "นอน", "!"}; !reflect.DeepEqual(expect, tokens) {
+		t.Errorf("Expect %v got %v", expect, tokens)
+	}
+	if expect := []bool{false, false, true, false, false, true, false}; !reflect.DeepEqual(expect, ends) {
+		t.Errorf("Expect %v got %v", expect, ends)
+	}
+}
+
+func TestSplitSentences(t *testing.T) {
+	tests := []struct {
+		text   string
+		expect []string
+	}{
+		{"วันนี้ฝนตกหนัก ฉันจึงไม่ได้ไปทำงาน", []string{"วันนี้ฝนตกหนัก", "ฉันจึงไม่ได้ไปทำงาน"}},
+		{"ราคา 100 บาท\nซื้อ iPhone ใหม่", []string{"ราคา 100 บาท", "ซื้อ iPhone ใหม่"}},
+		{"It rains. ฝนตก!  ", []string{"It rains.", "ฝนตก!"}},
+		{" \n ", nil},
+		{"ข้าว(สาร)ดี", []string{"ข้าว(สาร)ดี"}},
+		{`เขาพูดว่า"ไปกิน"แล้ว`, []string{`เขาพูดว่า"ไปกิน"แล้ว`}},
+		{"ไป\u00a0กิน", []string{"ไป", "กิน"}},
+	}
+	for _, test := range tests {
+		if got := SplitSentences(test.text); !reflect.DeepEqual(test.expect, got) {
+			t.Errorf("Expect %q got %q", test.expect, got)
+		}
+	}
+}
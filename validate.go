@@ -0,0 +1,24 @@
+package main
+
+// IsThaiCombining reports whether ch is a Thai vowel or tone mark written
+// above or below a consonant, it cannot begin a word
+func IsThaiCombining(ch rune) bool {
+	return ch == 'ั' ||
+		(ch >= 'ิ' && ch <= 'ฺ') ||
+		(ch >= '็' && ch <= '๎')
+}
+
+// MalformedStarts segments textRunes and returns the rune offsets of the
+// tokens beginning with a Thai combining mark, a sign of broken input such
+// as OCR output. Output filters of Segment are not applied.
+func (sm *Segmenter) MalformedStarts(textRunes []rune) []int {
+	_, offsets := sm.SegmentOffsets(textRunes)
+
+	var malformed []int
+	for _, offset := range offsets {
+		if IsThaiCombining(textRunes[offset]) {
+			malformed = append(malformed, offset)
+		}
+	}
+	return malformed
+}
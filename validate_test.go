@@ -0,0 +1,18 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMalformedStarts(t *testing.T) {
+	sm := NewSegmenter(MakePrefixTree([]string{"ไป", "กิน", "ข้าว"}))
+
+	if got := sm.MalformedStarts([]rune("ไปกินข้าว")); got != nil {
+		t.Errorf("Expect no malformed start got %v", got)
+	}
+
+	if expect, got := []int{0, 7}, sm.MalformedStarts([]rune("่ไปกิน ็ข้าว")); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// WatchDict polls the dictionary file path every second and calls onReload
+// with the reloaded dictionary whenever its modification time or size
+// changes. Pass w.SwapDict as onReload to hot-reload a worker. A file that
+// fails to load, like one being written, is retried on the next change.
+func WatchDict(path string, onReload func(PrefixTree)) (stop func(), err error) {
+	return WatchDictInterval(path, time.Second, onReload)
+}
+
+// WatchDictInterval watches path like WatchDict every interval
+func WatchDictInterval(path string, interval time.Duration, onReload func(PrefixTree)) (stop func(), err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, &DictError{path, err}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		modTime, size := info.ModTime(), info.Size()
+		for {
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+
+			info, err := os.Stat(path)
+			if err != nil || (info.ModTime().Equal(modTime) && info.Size() == size) {
+				continue
+			}
+			dict, err := LoadDict(path)
+			if err != nil {
+				continue
+			}
+			modTime, size = info.ModTime(), info.Size()
+			onReload(dict)
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }, nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchDict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dict.txt")
+	if err := os.WriteFile(path, []byte("ไป\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := make(chan PrefixTree, 1)
+	stop, err := WatchDictInterval(path, 10*time.Millisecond, func(dict PrefixTree) {
+		reloaded <- dict
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	if err := os.WriteFile(path, []byte("ไป\nกินข้าว\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case dict := <-reloaded:
+		if n := dict.LongestMatchAt([]rune("กินข้าว"), 0); n != 7 {
+			t.Errorf("Expect the reloaded dictionary to have กินข้าว got match length %d", n)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expect the dictionary to be reloaded")
+	}
+
+	if _, err := WatchDict(filepath.Join(t.TempDir(), "missing.txt"), func(PrefixTree) {}); err == nil {
+		t.Error("Expect error watching a missing file")
+	}
+}
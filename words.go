@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Order is the sort order of word lists written out of a PrefixTree
+type Order int
+
+const (
+	// ByteOrder sorts words by their UTF-8 bytes
+	ByteOrder Order = iota
+	// ThaiOrder sorts words in Thai dictionary order
+	ThaiOrder
+)
+
+// Words returns every word of the prefix tree in byte order
+func (t PrefixTree) Words() []string {
+	children := make(map[trieState][]trieEdge)
+	for node, child := range t {
+		state := trieState{node.NodeID, node.Offset}
+		children[state] = append(children[state], trieEdge{node.Ch, child})
+	}
+
+	var (
+		words  []string
+		prefix []rune
+		walk   func(state trieState)
+	)
+	walk = func(state trieState) {
+		for _, e := range children[state] {
+			prefix = append(prefix, e.Ch)
+			if e.IsFinal {
+				words = append(words, string(prefix))
+			}
+			walk(trieState{e.ChildID, state.Offset + 1})
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	walk(trieState{0, 0})
+
+	sort.Strings(words)
+	return words
+}
+
+// WriteText writes every word of the prefix tree to w, one word per line
+func (t PrefixTree) WriteText(w io.Writer, order Order) error {
+	words := t.Words()
+	if order == ThaiOrder {
+		SortThai(words)
+	}
+
+	out := bufio.NewWriter(w)
+	for _, word := range words {
+		out.WriteString(word)
+		out.WriteByte('\n')
+	}
+	return out.Flush()
+}
+
+// SortThai sorts words in Thai dictionary order without a collation
+// library, covering these rules of the Royal Institute Dictionary order:
+//   - the leading vowels เ แ โ ใ ไ sort after the consonant they are
+//     written before, as if written after it
+//   - the tone marks, mai taikhu and thanthakhat are ignored, they only
+//     break ties of otherwise equal words, in code point order
+//   - everything else compares by code point, which puts the consonants in
+//     alphabetical order with ฤ after ร and ฦ after ล, consonants before
+//     vowels and the vowels in dictionary order
+//
+// Other scripts, digits, ๆ and ฯ are only ordered by code point.
+func SortThai(words []string) {
+	keys := make(map[string][2]string, len(words))
+	for _, word := range words {
+		keys[word] = thaiCollateKey(word)
+	}
+
+	sort.SliceStable(words, func(i, j int) bool {
+		a, b := keys[words[i]], keys[words[j]]
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		return a[1] < b[1]
+	})
+}
+
+// thaiCollateKey returns the primary and secondary collation keys of word
+func thaiCollateKey(word string) [2]string {
+	runes := []rune(word)
+	for i := 0; i+1 < len(runes); i++ {
+		if isLeadingVowel(runes[i]) && !isLeadingVowel(runes[i+1]) {
+			runes[i], runes[i+1] = runes[i+1], runes[i]
+			i++
+		}
+	}
+
+	secondary := string(runes)
+	primary := strings.Map(func(ch rune) rune {
+		if isThaiDiacritic(ch) {
+			return -1
+		}
+		return ch
+	}, secondary)
+
+	return [2]string{primary, secondary}
+}
+
+// isLeadingVowel reports whether ch is a vowel written before its consonant
+func isLeadingVowel(ch rune) bool {
+	return ch >= 'เ' && ch <= 'ไ'
+}
+
+// isThaiDiacritic reports whether ch is a tone mark or other diacritic
+// ignored at the primary collation level
+func isThaiDiacritic(ch rune) bool {
+	return ch >= '็' && ch <= '์'
+}
+
+// DictOverlap is how much two prefix trees have in common
+type DictOverlap struct {
+	// SharedWords is the number of words in both trees
+	SharedWords int
+	// SharedPrefixes is the number of word prefixes, whole words included,
+	// in both trees
+	SharedPrefixes int
+}
+
+// Overlap compares the words of a and b
+func Overlap(a, b PrefixTree) DictOverlap {
+	var overlap DictOverlap
+
+	wordsA, wordsB := a.Words(), b.Words()
+	inB := make(map[string]bool, len(wordsB))
+	for _, word := range wordsB {
+		inB[word] = true
+	}
+	for _, word := range wordsA {
+		if inB[word] {
+			overlap.SharedWords++
+		}
+	}
+
+	prefixesB := wordPrefixes(wordsB)
+	for prefix := range wordPrefixes(wordsA) {
+		if prefixesB[prefix] {
+			overlap.SharedPrefixes++
+		}
+	}
+
+	return overlap
+}
+
+// wordPrefixes returns the set of non-empty prefixes of words
+func wordPrefixes(words []string) map[string]bool {
+	prefixes := make(map[string]bool)
+	for _, word := range words {
+		runes := []rune(word)
+		for i := 1; i <= len(runes); i++ {
+			prefixes[string(runes[:i])] = true
+		}
+	}
+	return prefixes
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestWords(t *testing.T) {
+	words := []string{"ไป", "กิน", "กา", "กาก", "ข้าว"}
+
+	expect := []string{"กา", "กาก", "กิน", "ข้าว", "ไป"}
+	if got := MakePrefixTree(words).Words(); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expect %v got %v", expect, got)
+	}
+}
+
+func TestWriteTextOrder(t *testing.T) {
+	dict := MakePrefixTree([]string{"ขา", "ไก่", "กา", "เกม", "ก้า"})
+
+	var out bytes.Buffer
+	if err := dict.WriteText(&out, ByteOrder); err != nil {
+		t.Fatal(err)
+	}
+	if expect := "กา\nก้า\nขา\nเกม\nไก่\n"; out.String() != expect {
+		t.Errorf("Expect %q got %q", expect, out.String())
+	}
+
+	out.Reset()
+	if err := dict.WriteText(&out, ThaiOrder); err != nil {
+		t.Fatal(err)
+	}
+	if expect := "กา\nก้า\nเกม\nไก่\nขา\n"; out.String() != expect {
+		t.Errorf("Expect %q got %q", expect, out.String())
+	}
+}
+
+func TestSortThai(t *testing.T) {
+	// in the order of the Royal Institute Dictionary
+	expect := []string{
+		"กก", "กด", "กะ", "กัด", "กา", "กิน", "กุ้ง",
+		"เก", "เก่ง", "แก", "แก้ว", "โกง", "ใกล้", "ไก่",
+		"ขา", "ข่า", "ข้า", "ข้าง", "ขาด",
+		"รัก", "ฤดู", "ลม", "ฦๅ", "วัน",
+	}
+
+	words := append([]string(nil), expect...)
+	rand.New(rand.NewSource(1)).Shuffle(len(words), func(i, j int) {
+		words[i], words[j] = words[j], words[i]
+	})
+	SortThai(words)
+	if !reflect.DeepEqual(expect, words) {
+		t.Errorf("Expect %v got %v", expect, words)
+	}
+}
+
+func TestOverlap(t *testing.T) {
+	a := MakePrefixTree([]string{"กิน", "กินข้าว", "ไป", "มา"})
+	b := MakePrefixTree([]string{"กิน", "กินน้ำ", "ไป", "นอน"})
+
+	// shared prefixes are ก กิ กิน ไ ไป
+	expect := DictOverlap{SharedWords: 2, SharedPrefixes: 5}
+	if got := Overlap(a, b); got != expect {
+		t.Errorf("Expect %+v got %+v", expect, got)
+	}
+	if got := Overlap(b, a); got != expect {
+		t.Errorf("Expect %+v got %+v", expect, got)
+	}
+}